@@ -1,15 +1,188 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/clusterguard"
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/declaration"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/events"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/kubeclient"
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/okctlstate"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/prompt"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/somecomponent"
+	"k8s.io/client-go/kubernetes"
 )
 
 type Context struct {
-	logger logger.Logger
+	logger    logger.Logger
+	prompter  prompt.Prompter
+	clientSet kubernetes.Interface
+	record    engine.Record
+
+	// clusterName is empty unless set in the dependencies, see checkCluster
+	clusterName string
+	events      *events.Emitter
 }
 
-func newContext(flags cmdflags.Flags) Context {
+// Dependencies are the parts of the environment the upgrade talks to. Tests replace them with fakes, see
+// main_test.go.
+type Dependencies struct {
+	// Prompter asks the user questions. Defaults to a prompt in the terminal, or the answers in --answers.
+	Prompter prompt.Prompter
+
+	// ClientSet is the cluster to upgrade. Defaults to the cluster in KUBECONFIG.
+	ClientSet kubernetes.Interface
+
+	// ClusterName is the name the user must type to run destructive steps. Defaults to the name in the cluster
+	// declaration, after checking that KUBECONFIG and the AWS credentials point at that cluster. The check is skipped
+	// when the name is set.
+	ClusterName string
+
+	// Record is okctl's record of whether the upgrade has been applied. Defaults to the record in okctl's local state of
+	// the cluster in the cluster declaration.
+	Record engine.Record
+}
+
+func newContext(flags cmdflags.Flags, deps Dependencies) (Context, error) {
+	log, err := newLogger(flags)
+	if err != nil {
+		return Context{}, err
+	}
+
+	if flags.Events == "jsonl" && flags.Output != string(output.FormatText) {
+		return Context{}, errors.New("--events=jsonl and --output=json|yaml can't be combined, as both write to stdout")
+	}
+
+	emitter, err := events.Open(flags.Events)
+	if err != nil {
+		return Context{}, err
+	}
+
+	prompter, err := newPrompter(flags, deps)
+	if err != nil {
+		return Context{}, err
+	}
+
+	return Context{
+		logger:      log,
+		prompter:    prompter,
+		clientSet:   deps.ClientSet,
+		record:      deps.Record,
+		clusterName: deps.ClusterName,
+		events:      emitter,
+	}, nil
+}
+
+// checkCluster refuses to run if KUBECONFIG or the AWS credentials point at another cluster than the cluster
+// declaration, and returns the name of the cluster
+func (c Context) checkCluster() (string, error) {
+	if c.clusterName != "" {
+		return c.clusterName, nil
+	}
+
+	declared, err := declaredCluster()
+	if err != nil {
+		return "", err
+	}
+
+	env, err := clusterguard.FromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	err = clusterguard.Check(declared, env)
+	if err != nil {
+		return "", engine.PreflightError{Err: err}
+	}
+
+	return declared.Name, nil
+}
+
+// newComponent returns the component in the cluster from the dependencies, or else in the cluster in KUBECONFIG, using
+// a client with the given options
+func newComponent(context Context, opts kubeclient.Opts) (somecomponent.SomeComponent, error) {
+	if context.clientSet != nil {
+		return somecomponent.New(context.logger, context.clientSet), nil
+	}
+
+	clientSet, err := kubeclient.NewFromEnv(opts)
+	if err != nil {
+		return somecomponent.SomeComponent{}, fmt.Errorf("acquiring kubectl client: %w", err)
+	}
+
+	return somecomponent.New(context.logger, clientSet), nil
+}
+
+// newRecord returns the record from the dependencies, or else okctl's record of this upgrade in the local state
+func newRecord(context Context) (engine.Record, error) {
+	if context.record != nil {
+		return context.record, nil
+	}
+
+	records, err := localRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	return records.For(somecomponent.Info().Name()), nil
+}
+
+// localRecords returns okctl's records of which upgrades have been applied to the cluster in the cluster declaration,
+// which are kept in the local state
+func localRecords() (okctlstate.Records, error) {
+	d, err := declaration.FromEnv()
+	if err != nil {
+		return okctlstate.Records{}, err
+	}
+
+	path, err := okctlstate.LocalStatePath(d.Metadata.Name)
+	if err != nil {
+		return okctlstate.Records{}, err
+	}
+
+	file, err := okctlstate.OpenFile(path)
+	if err != nil {
+		return okctlstate.Records{}, fmt.Errorf("%w. Download it with okctl maintenance state-download, see the README",
+			err)
+	}
+
+	return okctlstate.New(file, okctlstate.ID{
+		Region:       d.Metadata.Region,
+		AWSAccountID: d.Metadata.AccountID,
+		ClusterName:  d.Metadata.Name,
+	}), nil
+}
+
+// declaredCluster returns the cluster in the cluster declaration
+func declaredCluster() (clusterguard.Cluster, error) {
+	d, err := declaration.FromEnv()
+	if err != nil {
+		return clusterguard.Cluster{}, err
+	}
+
+	return clusterguard.Cluster{
+		Name:      d.Metadata.Name,
+		Region:    d.Metadata.Region,
+		AccountID: d.Metadata.AccountID,
+	}, nil
+}
+
+// newPrompter returns the answers in --answers if set, or else the prompter from the dependencies
+func newPrompter(flags cmdflags.Flags, deps Dependencies) (prompt.Prompter, error) {
+	if flags.Answers == "" {
+		return deps.Prompter, nil
+	}
+
+	return prompt.LoadAnswers(flags.Answers)
+}
+
+func newLogger(flags cmdflags.Flags) (logger.Logger, error) {
 	var level logger.Level
 	if flags.Debug {
 		level = logger.Debug
@@ -17,7 +190,31 @@ func newContext(flags cmdflags.Flags) Context {
 		level = logger.Info
 	}
 
-	return Context{
-		logger: logger.New(level),
+	format, err := logger.ParseFormat(flags.LogFormat)
+	if err != nil {
+		return logger.Logger{}, err
 	}
+
+	opts := logger.Opts{
+		Level:  level,
+		Format: format,
+		Out:    os.Stdout,
+	}
+
+	// Keep stdout for machine-readable output
+	if flags.Output != string(output.FormatText) || flags.Events == "jsonl" {
+		opts.Out = os.Stderr
+	}
+
+	if flags.LogFile != "" {
+		// Left open until the process exits. Every message is written straight to the file, so nothing is lost.
+		file, err := os.OpenFile(flags.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return logger.Logger{}, fmt.Errorf("opening log file: %w", err)
+		}
+
+		opts.File = file
+	}
+
+	return logger.NewWithOpts(opts), nil
 }
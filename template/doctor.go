@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/declaration"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/doctor"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/somecomponent"
+	"github.com/spf13/cobra"
+)
+
+func buildDoctorCommand(flags *cmdflags.Flags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Checks every prerequisite for running the upgrade, and shows how to fix what's missing",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			format, err := output.ParseFormat(flags.Output)
+			if err != nil {
+				return err
+			}
+
+			results := doctor.Run(doctorChecks())
+
+			err = doctor.Write(os.Stdout, format, results)
+			if err != nil {
+				return err
+			}
+
+			return doctor.Failed(results)
+		},
+	}
+}
+
+// doctorChecks returns the checks for the environment variables and the cluster the upgrade needs
+func doctorChecks() []doctor.Check {
+	var checks []doctor.Check
+
+	for _, name := range somecomponent.Info().RequiredEnv {
+		checks = append(checks, doctor.Env(name))
+	}
+
+	return append(checks,
+		doctor.Check{
+			Name: "Cluster declaration",
+			Run: func() (string, error) {
+				d, err := declaration.FromEnv()
+				if err != nil {
+					return "", err
+				}
+
+				return d.Metadata.Name, nil
+			},
+			Fix: "Set " + declaration.EnvPath + " to the path of the cluster's declaration, and check that it has " +
+				"a metadata.name.",
+		},
+		doctor.Kubernetes(),
+		doctor.AWSCredentials(),
+		doctor.SameCluster(declaredCluster),
+	)
+}
@@ -5,28 +5,34 @@ import (
 	"fmt"
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/cmdflags"
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/exitcode"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/info"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/somecomponent"
 	"github.com/spf13/cobra"
 	"os"
 	"path/filepath"
 )
 
 func main() {
-	cmd := buildRootCommand()
+	cmd := buildRootCommand(Dependencies{})
 
 	err := cmd.Execute()
 
-	if err != nil && errors.Is(err, commonerrors.ErrUserAborted) {
-		fmt.Println("Upgrade aborted by user.")
-	} else if err != nil {
+	switch {
+	case errors.Is(err, commonerrors.ErrUserAborted):
+		_, _ = fmt.Fprintln(os.Stderr, "Upgrade aborted by user.")
+	case errors.Is(err, commonerrors.ErrNothingToDo):
+		// Only returned with --report-skipped, and the upgrade has already said there was nothing to do
+	case err != nil:
 		_, _ = fmt.Fprintln(os.Stderr, err.Error())
 	}
 
-	if err != nil {
-		os.Exit(1)
-	}
+	os.Exit(exitcode.For(err))
 }
 
-func buildRootCommand() *cobra.Command {
+func buildRootCommand(deps Dependencies) *cobra.Command {
 	flags := cmdflags.Flags{}
 
 	var context Context
@@ -41,10 +47,17 @@ func buildRootCommand() *cobra.Command {
 		SilenceErrors: true, // true as we print errors in the main() function
 		SilenceUsage:  true, // true because we don't want to show usage if an errors occurs
 		PreRunE: func(_ *cobra.Command, args []string) error {
-			context = newContext(flags)
-			return nil
+			var err error
+
+			context, err = newContext(flags, deps)
+
+			return err
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			if flags.Info {
+				return printInfo(flags)
+			}
+
 			return upgrade(context, flags)
 		},
 	}
@@ -59,10 +72,84 @@ func buildRootCommand() *cobra.Command {
 	 *				If set to false, the upgrade will make actual changes.
 	 *
 	 * --confirm:	Skips all confirmation prompts, if any.
+	 *
+	 * --answers:	Answers prompts from this YAML file instead of asking in the terminal. The file maps question IDs,
+	 *				as in the prompt_needed events, to true or false, or to the text to type, such as the cluster name.
+	 *				A question missing from the file fails the upgrade.
+	 *
+	 * --i-understand-data-loss:	Runs steps that delete data without asking the user to type the cluster name.
+	 *								Required to run such steps with --confirm or apply.
+	 *
+	 * --no-rollback:	If a step fails, leave the cluster as it is instead of running the recorded undo actions.
+	 *					Useful for debugging a failed upgrade.
+	 *
+	 * --output:	text, json or yaml. With json or yaml, --dry-run prints a plan of every intended action to stdout
+	 *				instead of log output, which is written to stderr.
+	 *
+	 * --log-format:	text or json. With json, every log message is written as a JSON object on a single line, with
+	 *					a timestamp and fields like the upgrade and step it belongs to.
+	 *
+	 * --log-file:	Also writes every log message, including debug messages, as JSON to this file.
+	 *
+	 * --events:	jsonl or fd:<n>. Writes progress events, such as step started and finished, as JSON objects to
+	 *				stdout or to the given file descriptor, one per line, for okctl to render. See pkg/lib/events.
+	 *
+	 * --report-skipped:	Exit with code 3 instead of 0 if there was nothing to do. See pkg/lib/exitcode for every
+	 *						exit code.
+	 *
+	 * --info:		Prints what the upgrade does, such as the okctl version it targets, the components it changes and
+	 *				whether it is destructive, instead of running it. Use --output=json or yaml for machine-readable output.
+	 *
+	 * --trace:		Records every Kubernetes request and response to the given file, one JSON object per line. Attach
+	 *				the file to support requests about failed upgrades.
+	 *
+	 * --record:	Records the Kubernetes requests that reach the cluster to the given cassette file, for replaying in
+	 *				tests, see pkg/lib/replay. Meant for developers running the upgrade against a staging cluster.
+	 *
+	 * Subcommands:
+	 *
+	 * plan --out <file>:	Saves the plan to a file instead of running the upgrade.
+	 *
+	 * apply <file>:		Runs the upgrade without prompting, if the cluster still matches the preconditions in the
+	 *						plan. Only simulates if --dry-run is explicitly set.
+	 *
+	 * state list:			Lists the upgrades okctl has recorded as applied in the local state.
+	 *
+	 * state reset <version>:	Removes the record of an upgrade, so okctl upgrade runs it again. Only simulates
+	 *							if --dry-run is explicitly set.
+	 *
+	 * doctor:				Checks every prerequisite at once, such as the environment variables from okctl venv, access
+	 *						to the cluster and AWS, and the cluster declaration, and prints how to fix what's missing.
 	 */
 	cmd.PersistentFlags().BoolVarP(&flags.Debug, "debug", "d", false, "Set this to enable debug output.")
 	cmd.PersistentFlags().BoolVarP(&flags.DryRun, "dry-run", "n", true, "Don't actually do any changes, just show what would be done.")
 	cmd.PersistentFlags().BoolVarP(&flags.Confirm, "confirm", "c", false, "Set this to skip confirmation prompts.")
+	cmd.PersistentFlags().StringVar(&flags.Answers, "answers", "", "Answer prompts from this YAML file, keyed by question ID.")
+	cmd.PersistentFlags().BoolVar(&flags.AcceptDataLoss, "i-understand-data-loss", false, "Set this to run steps that delete data without typing the cluster name.")
+	cmd.PersistentFlags().BoolVar(&flags.NoRollback, "no-rollback", false, "Set this to keep the cluster as it is if the upgrade fails.")
+	cmd.PersistentFlags().StringVarP(&flags.Output, "output", "o", string(output.FormatText), "Set to json or yaml to print the plan in dry-run mode.")
+	cmd.PersistentFlags().StringVar(&flags.LogFormat, "log-format", string(logger.FormatText), "Set to json to write log messages as JSON.")
+	cmd.PersistentFlags().StringVar(&flags.LogFile, "log-file", "", "Also write every log message as JSON to this file.")
+	cmd.PersistentFlags().StringVar(&flags.Events, "events", "", "Set to jsonl or fd:<n> to write progress events to stdout or a file descriptor.")
+	cmd.PersistentFlags().BoolVar(&flags.ReportSkipped, "report-skipped", false, "Set this to exit with code 3 instead of 0 if there was nothing to do.")
+	cmd.PersistentFlags().BoolVar(&flags.Info, "info", false, "Set this to print what the upgrade does instead of running it.")
+	cmd.PersistentFlags().StringVar(&flags.Trace, "trace", "", "Record every Kubernetes request to this file, for debugging.")
+	cmd.PersistentFlags().StringVar(&flags.Record, "record", "", "Record Kubernetes requests to this cassette file, for replaying in tests.")
+
+	cmd.AddCommand(buildPlanCommand(&flags, deps))
+	cmd.AddCommand(buildApplyCommand(&flags, deps))
+	cmd.AddCommand(buildStateCommand(&flags))
+	cmd.AddCommand(buildDoctorCommand(&flags))
 
 	return cmd
 }
+
+// printInfo prints the upgrade's metadata in the format given by --output
+func printInfo(flags cmdflags.Flags) error {
+	format, err := output.ParseFormat(flags.Output)
+	if err != nil {
+		return err
+	}
+
+	return info.Write(os.Stdout, format, somecomponent.Info())
+}
@@ -1,7 +1,18 @@
 package cmdflags
 
 type Flags struct {
-	Debug   bool
-	DryRun  bool
-	Confirm bool
+	Debug          bool
+	DryRun         bool
+	Confirm        bool
+	Answers        string
+	AcceptDataLoss bool
+	NoRollback     bool
+	Output         string
+	LogFormat      string
+	LogFile        string
+	Events         string
+	ReportSkipped  bool
+	Info           bool
+	Trace          string
+	Record         string
 }
@@ -0,0 +1,58 @@
+// Package declaration reads the parts of okctl's cluster declaration that upgrades need, for upgrades that don't
+// depend on okctl itself
+package declaration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPath is the environment variable okctl sets to the path of the cluster declaration
+const EnvPath = "OKCTL_CLUSTER_DECLARATION"
+
+// Metadata identifies the cluster
+type Metadata struct {
+	Name      string `json:"name"`
+	Region    string `json:"region"`
+	AccountID string `json:"accountID"`
+}
+
+// Declaration is the part of the cluster declaration upgrades use
+type Declaration struct {
+	Metadata Metadata `json:"metadata"`
+}
+
+// Read reads the cluster declaration at the given path
+func Read(path string) (Declaration, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Declaration{}, fmt.Errorf("reading cluster declaration: %w", err)
+	}
+
+	var d Declaration
+
+	err = yaml.Unmarshal(raw, &d)
+	if err != nil {
+		return Declaration{}, fmt.Errorf("unmarshalling cluster declaration %s: %w", path, err)
+	}
+
+	if d.Metadata.Name == "" {
+		return Declaration{}, fmt.Errorf("cluster declaration %s has no metadata.name", path)
+	}
+
+	return d, nil
+}
+
+// FromEnv reads the cluster declaration at the path in EnvPath
+func FromEnv() (Declaration, error) {
+	path := os.Getenv(EnvPath)
+	if path == "" {
+		return Declaration{}, fmt.Errorf("missing required %s environment variable", EnvPath)
+	}
+
+	return Read(path)
+}
@@ -0,0 +1,93 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/output"
+)
+
+func TestDoctor(t *testing.T) {
+	pass := func() (string, error) { return "/some/path", nil }
+	fail := func() (string, error) { return "", errors.New("wrong:\n  first\n  second") }
+
+	testCases := []struct {
+		name        string
+		checks      []Check
+		expect      string
+		expectError bool
+	}{
+		{
+			name:   "Should show passing checks",
+			checks: []Check{{Name: "first", Run: pass, Fix: "Do something."}},
+			expect: "CHECK  RESULT  DETAILS\n" +
+				"first  ok      /some/path\n",
+		},
+		{
+			name: "Should run every check and show how to fix the failures",
+			checks: []Check{
+				{Name: "first", Run: fail, Fix: "Do something."},
+				{Name: "second check", Run: pass, Fix: "Do something else."},
+				{Name: "third", Run: fail},
+			},
+			expect: "CHECK         RESULT  DETAILS\n" +
+				"first         FAIL    wrong: first second\n" +
+				"second check  ok      /some/path\n" +
+				"third         FAIL    wrong: first second\n" +
+				"\nTo fix:\n" +
+				"  first: Do something.\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			results := Run(tc.checks)
+
+			out := &bytes.Buffer{}
+
+			err := Write(out, output.FormatText, results)
+			if err != nil {
+				t.Fatalf("writing results: %s", err)
+			}
+
+			if out.String() != tc.expect {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expect, out.String())
+			}
+
+			err = Failed(results)
+			if tc.expectError != errors.Is(err, ErrFailed) {
+				t.Errorf("expected error: %t, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	const name = "OKCTL_UPGRADE_DOCTOR_TEST"
+
+	check := Env(name)
+
+	_, err := check.Run()
+	if err == nil {
+		t.Errorf("expected an error when %s isn't set", name)
+	}
+
+	err = os.Setenv(name, "/some/path")
+	if err != nil {
+		t.Fatalf("setting %s: %s", name, err)
+	}
+
+	defer func() {
+		_ = os.Unsetenv(name)
+	}()
+
+	detail, err := check.Run()
+	if err != nil || detail != "/some/path" {
+		t.Errorf("expected /some/path, got %s and %v", detail, err)
+	}
+}
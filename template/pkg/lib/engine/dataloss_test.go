@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/prompt"
+)
+
+func TestConfirmDataLoss(t *testing.T) {
+	skip := func() error { return commonerrors.ErrNothingToDo }
+
+	testCases := []struct {
+		name          string
+		opts          Opts
+		answers       map[string]interface{}
+		preflight     func() error
+		expectError   error
+		expectApplied bool
+	}{
+		{
+			name:          "Should run destructive steps when the user types the cluster name",
+			opts:          Opts{ClusterName: "my-cluster"},
+			answers:       map[string]interface{}{QuestionContinue.ID: true, QuestionClusterName.ID: "my-cluster"},
+			expectApplied: true,
+		},
+		{
+			name:        "Should abort when the user types another name",
+			opts:        Opts{ClusterName: "my-cluster"},
+			answers:     map[string]interface{}{QuestionContinue.ID: true, QuestionClusterName.ID: "y"},
+			expectError: commonerrors.ErrUserAborted,
+		},
+		{
+			name:        "Should refuse to run destructive steps with only --confirm",
+			opts:        Opts{Confirm: true, ClusterName: "my-cluster"},
+			expectError: ErrDataLossNotAccepted,
+		},
+		{
+			name:          "Should run destructive steps with --confirm when data loss is accepted",
+			opts:          Opts{Confirm: true, AcceptDataLoss: true},
+			expectApplied: true,
+		},
+		{
+			name: "Should not ask for the cluster name in dry-run mode",
+			opts: Opts{DryRun: true},
+		},
+		{
+			name:      "Should not ask for the cluster name when the destructive step is skipped",
+			opts:      Opts{Confirm: true},
+			preflight: skip,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			applied := false
+
+			tc.opts.Prompter = prompt.NewTest(t, tc.answers)
+
+			e := New(logger.New(logger.Error), tc.opts)
+
+			_, err := e.Run(Upgrade{Name: "test", Steps: []Step{{
+				Name:        "delete-logs",
+				Destructive: true,
+				Preflight:   tc.preflight,
+				Apply: func(_ *Undo) error {
+					applied = true
+
+					return nil
+				},
+			}}})
+
+			if tc.expectError == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if tc.expectError != nil && !errors.Is(err, tc.expectError) {
+				t.Fatalf("expected error %v, got %v", tc.expectError, err)
+			}
+
+			if applied != tc.expectApplied {
+				t.Errorf("expected applied: %t, got %t", tc.expectApplied, applied)
+			}
+		})
+	}
+}
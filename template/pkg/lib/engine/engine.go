@@ -0,0 +1,578 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/events"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/prompt"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/rbac"
+)
+
+// Run runs all preflight checks, asks the user for confirmation, and then applies and verifies each step in order.
+// If a step fails, the undo actions recorded so far are run in reverse order.
+func (e Engine) Run(u Upgrade) (Summary, error) {
+	return e.run(u, nil)
+}
+
+// run runs the upgrade, and emits an event with the result. If a plan is given, the upgrade is aborted if the
+// preflight checks don't agree with it.
+func (e Engine) run(u Upgrade, plan *Plan) (Summary, error) {
+	e.log = e.log.With("upgrade", u.Name)
+	e.upgrade = u.Name
+
+	summary, err := e.runSteps(u, plan)
+
+	e.emitCompleted(summary, err)
+
+	if err == nil && e.reportSkipped && result(summary, nil) == events.ResultSkipped {
+		return summary, commonerrors.ErrNothingToDo
+	}
+
+	return summary, err
+}
+
+func (e Engine) runSteps(u Upgrade, plan *Plan) (Summary, error) {
+	e.log.Infof("Upgrading %s\n", u.Name)
+
+	summary := newSummary(u.Steps)
+
+	err := e.resume(&summary)
+	if err != nil {
+		return summary, err
+	}
+
+	pending, err := e.preflight(u, &summary)
+	if err != nil {
+		return summary, PreflightError{Err: err}
+	}
+
+	if plan != nil {
+		err = checkPlannedSteps(*plan, summary)
+		if err != nil {
+			return summary, PreflightError{Err: err}
+		}
+	}
+
+	if pending == 0 {
+		e.log.Infof("Nothing to do, %s is already up to date\n", u.Name)
+		e.emit(events.Event{Type: events.TypeNothingToDo})
+		e.printSummary(summary)
+
+		err = e.clearCheckpoint()
+		if err != nil {
+			return summary, err
+		}
+
+		return summary, e.markApplied()
+	}
+
+	err = e.checkAccess(u, summary)
+	if err != nil {
+		return summary, PreflightError{Err: err}
+	}
+
+	if !e.dryRun && !e.confirm {
+		answer, err := e.askUser(QuestionContinue)
+		if err != nil {
+			return summary, fmt.Errorf("prompting user: %w", err)
+		}
+
+		if !answer {
+			return summary, commonerrors.ErrUserAborted
+		}
+	}
+
+	if !e.dryRun {
+		err = e.confirmDataLoss(u, summary)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	undo := &Undo{}
+
+	for i, step := range u.Steps {
+		if summary.Steps[i].Status != StatusPending {
+			e.emitStep(events.TypeStepFinished, summary, i, nil)
+
+			continue
+		}
+
+		if e.stopped() {
+			return summary, e.fail(&summary, undo, fmt.Errorf("%w before step %s", ErrStopped, step.Name))
+		}
+
+		log := e.log.With("step", step.Name)
+
+		e.emitStep(events.TypeStepStarted, summary, i, nil)
+
+		if e.dryRun {
+			log.Infof("Simulating step: %s\n", step.Name)
+			summary.Steps[i].Status = StatusSimulated
+			e.emitStep(events.TypeStepFinished, summary, i, nil)
+
+			continue
+		}
+
+		log.Infof("Running step: %s\n", step.Name)
+
+		undo.currentStep = i
+		if step.Rollback != nil {
+			undo.Add(fmt.Sprintf("roll back step %s", step.Name), step.Rollback)
+		}
+
+		err = step.Apply(undo)
+		if err != nil {
+			summary.Steps[i].Status = StatusFailed
+			e.emitStep(events.TypeStepFinished, summary, i, err)
+
+			return summary, e.fail(&summary, undo, fmt.Errorf("applying step %s: %w", step.Name, err))
+		}
+
+		if step.Verify != nil {
+			log.Debugf("Verifying step: %s\n", step.Name)
+
+			err = step.Verify()
+			if err != nil {
+				summary.Steps[i].Status = StatusFailed
+				e.emitStep(events.TypeStepFinished, summary, i, err)
+
+				return summary, e.fail(&summary, undo, fmt.Errorf("verifying step %s: %w", step.Name, err))
+			}
+		}
+
+		summary.Steps[i].Status = StatusApplied
+		e.emitStep(events.TypeStepFinished, summary, i, nil)
+
+		err = e.saveCheckpoint(summary)
+		if err != nil {
+			return summary, e.fail(&summary, undo, err)
+		}
+	}
+
+	e.printSummary(summary)
+	e.log.Infof("Upgrading %s done!\n", u.Name)
+
+	err = e.clearCheckpoint()
+	if err != nil {
+		return summary, err
+	}
+
+	return summary, e.markApplied()
+}
+
+// resume marks the steps completed by a previous, interrupted run
+func (e Engine) resume(summary *Summary) error {
+	if e.checkpoints == nil {
+		return nil
+	}
+
+	completed, err := e.checkpoints.Load()
+	if err != nil {
+		return fmt.Errorf("loading checkpoint: %w", err)
+	}
+
+	if len(completed) == 0 {
+		return nil
+	}
+
+	e.log.Info("Resuming a previous run of this upgrade that did not complete. Steps already applied:")
+
+	isCompleted := make(map[string]bool, len(completed))
+	for _, name := range completed {
+		isCompleted[name] = true
+	}
+
+	for i, step := range summary.Steps {
+		if isCompleted[step.Name] {
+			e.log.Infof("- %s\n", step.Name)
+			summary.Steps[i].Status = StatusAppliedPreviously
+		}
+	}
+
+	return nil
+}
+
+// preflight checks if the upgrade has been applied already, runs the upgrade's and every pending step's preflight
+// check, and returns the number of steps left to apply. When resuming, the upgrade's preflight check is not run, as
+// it checks the state from before the upgrade.
+func (e Engine) preflight(u Upgrade, summary *Summary) (int, error) {
+	if e.record != nil {
+		applied, err := e.record.IsApplied()
+		if err != nil {
+			return 0, fmt.Errorf("checking if upgrade has been applied: %w", err)
+		}
+
+		if applied {
+			e.log.Infof("The okctl state says %s has already been upgraded\n", u.Name)
+
+			for i := range summary.Steps {
+				summary.Steps[i].Status = StatusSkipped
+			}
+
+			return 0, nil
+		}
+	}
+
+	if u.Preflight != nil && !summary.isResumed() {
+		err := u.Preflight()
+		if errors.Is(err, commonerrors.ErrNothingToDo) {
+			for i := range summary.Steps {
+				summary.Steps[i].Status = StatusSkipped
+			}
+
+			return 0, nil
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("running preflight checks: %w", err)
+		}
+	}
+
+	pending := 0
+
+	for i, step := range u.Steps {
+		if summary.Steps[i].Status != StatusPending {
+			continue
+		}
+
+		if step.Preflight == nil {
+			pending++
+			continue
+		}
+
+		err := step.Preflight()
+		if errors.Is(err, commonerrors.ErrNothingToDo) {
+			e.log.With("step", step.Name).Debugf("Nothing to do for step: %s\n", step.Name)
+			summary.Steps[i].Status = StatusSkipped
+
+			continue
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("running preflight checks for step %s: %w", step.Name, err)
+		}
+
+		pending++
+	}
+
+	return pending, nil
+}
+
+// stopped is true if Opts.Stop has been closed
+func (e Engine) stopped() bool {
+	select {
+	case <-e.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkAccess checks that the user has the permissions every pending step needs, so that the upgrade doesn't fail
+// halfway. It runs in dry-run mode too, as it changes nothing.
+func (e Engine) checkAccess(u Upgrade, summary Summary) error {
+	if e.access == nil {
+		return nil
+	}
+
+	var permissions []rbac.Permission
+
+	for i, step := range u.Steps {
+		if summary.Steps[i].Status == StatusPending {
+			permissions = append(permissions, step.Permissions...)
+		}
+	}
+
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	return e.access.Check(permissions)
+}
+
+// fail runs the recorded undo actions, unless rollback is disabled, and returns an ApplyError with the error that
+// caused the failure
+func (e Engine) fail(summary *Summary, undo *Undo, cause error) error {
+	e.log.Info(cause.Error())
+
+	if e.noRollback {
+		e.log.Info("Not rolling back, as rollback is disabled. The following undo actions were not run:")
+
+		for i := len(undo.actions) - 1; i >= 0; i-- {
+			e.log.Infof("- %s\n", undo.actions[i].description)
+		}
+
+		e.printSummary(*summary)
+
+		return ApplyError{Err: cause}
+	}
+
+	err := e.rollback(summary, undo)
+
+	e.printSummary(*summary)
+
+	if err != nil {
+		return ApplyError{Err: fmt.Errorf("%w (rollback failed: %s)", cause, err.Error())}
+	}
+
+	// The undo actions of steps applied by a previous run were lost with that run, so those steps are still applied
+	rolledBack := !summary.isResumed()
+	if !rolledBack {
+		e.log.Info("The following steps were applied by a previous run, and can't be rolled back:")
+
+		for _, step := range summary.Steps {
+			if step.Status == StatusAppliedPreviously {
+				e.log.Infof("- %s\n", step.Name)
+			}
+		}
+	}
+
+	err = e.saveCheckpoint(*summary)
+	if err != nil {
+		return ApplyError{Err: fmt.Errorf("%w (%s)", cause, err.Error()), RolledBack: rolledBack}
+	}
+
+	return ApplyError{Err: cause, RolledBack: rolledBack}
+}
+
+// rollback runs the recorded undo actions in reverse order. It stops at the first action that fails, as later
+// actions may depend on it.
+func (e Engine) rollback(summary *Summary, undo *Undo) error {
+	for i := len(undo.actions) - 1; i >= 0; i-- {
+		action := undo.actions[i]
+
+		e.log.Infof("Rolling back: %s\n", action.description)
+
+		err := action.fn()
+		if err != nil {
+			return fmt.Errorf("%s: %w", action.description, err)
+		}
+
+		if undo.isLastActionForStep(i) {
+			summary.Steps[action.step].Status = StatusRolledBack
+		}
+	}
+
+	// Steps without undo actions have nothing to revert. Marking them rolled back keeps them out of the checkpoint,
+	// so that the next run starts from the beginning instead of resuming after them.
+	for i := range summary.Steps {
+		if summary.Steps[i].Status == StatusApplied {
+			summary.Steps[i].Status = StatusRolledBack
+		}
+	}
+
+	return nil
+}
+
+// saveCheckpoint stores the steps that are currently applied. Nothing is stored in dry-run mode.
+func (e Engine) saveCheckpoint(summary Summary) error {
+	if e.checkpoints == nil || e.dryRun {
+		return nil
+	}
+
+	err := e.checkpoints.Save(summary.applied())
+	if err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// clearCheckpoint removes the checkpoint when the upgrade has completed, so that a later run starts from scratch
+func (e Engine) clearCheckpoint() error {
+	if e.checkpoints == nil || e.dryRun {
+		return nil
+	}
+
+	err := e.checkpoints.Save(nil)
+	if err != nil {
+		return fmt.Errorf("clearing checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// markApplied records that the upgrade has been applied. Nothing is recorded in dry-run mode.
+func (e Engine) markApplied() error {
+	if e.record == nil || e.dryRun {
+		return nil
+	}
+
+	err := e.record.MarkApplied()
+	if err != nil {
+		return fmt.Errorf("marking upgrade as applied: %w", err)
+	}
+
+	return nil
+}
+
+func (e Engine) printSummary(summary Summary) {
+	e.log.Info("Summary:")
+
+	for _, step := range summary.Steps {
+		e.log.Infof("- %s: %s\n", step.Name, step.Status)
+	}
+}
+
+// confirmDataLoss makes the user type the cluster name before any destructive step runs, so that a habitual yes
+// doesn't delete data, or upgrade the wrong cluster
+func (e Engine) confirmDataLoss(u Upgrade, summary Summary) error {
+	var destructive []string
+
+	for i, step := range u.Steps {
+		if step.Destructive && summary.Steps[i].Status == StatusPending {
+			destructive = append(destructive, step.Name)
+		}
+	}
+
+	if len(destructive) == 0 || e.acceptDataLoss {
+		return nil
+	}
+
+	if e.confirm {
+		return PreflightError{Err: fmt.Errorf("%w: steps %s delete data that can't be restored, set "+
+			"--i-understand-data-loss to run them without typing the cluster name", ErrDataLossNotAccepted,
+			strings.Join(destructive, ", "))}
+	}
+
+	if e.clusterName == "" {
+		return errors.New("no cluster name to confirm destructive steps with")
+	}
+
+	warning := fmt.Sprintf("Steps %s delete data that can't be restored. Type the name of the cluster, %s, to "+
+		"continue.", strings.Join(destructive, ", "), e.clusterName)
+
+	e.log.Info(warning)
+	e.emit(events.Event{Type: events.TypeWarning, Message: warning})
+
+	answer, err := e.input(QuestionClusterName)
+	if err != nil {
+		return fmt.Errorf("prompting user: %w", err)
+	}
+
+	if strings.TrimSpace(answer) != e.clusterName {
+		e.log.Infof("%s is not the name of the cluster\n", answer)
+
+		return commonerrors.ErrUserAborted
+	}
+
+	return nil
+}
+
+func (e Engine) input(q prompt.Question) (string, error) {
+	e.emit(events.Event{Type: events.TypePromptNeeded, Message: q.Message, Question: q.ID})
+
+	return e.prompter.Input(q)
+}
+
+func (e Engine) askUser(q prompt.Question) (bool, error) {
+	e.emit(events.Event{Type: events.TypePromptNeeded, Message: q.Message, Question: q.ID})
+
+	return e.prompter.Confirm(q)
+}
+
+func newSummary(steps []Step) Summary {
+	results := make([]StepResult, len(steps))
+
+	for i, step := range steps {
+		results[i] = StepResult{
+			Name:   step.Name,
+			Status: StatusPending,
+		}
+	}
+
+	return Summary{Steps: results}
+}
+
+func (s Summary) applied() []string {
+	names := make([]string, 0, len(s.Steps))
+
+	for _, step := range s.Steps {
+		if step.Status == StatusApplied || step.Status == StatusAppliedPreviously {
+			names = append(names, step.Name)
+		}
+	}
+
+	return names
+}
+
+func (s Summary) isResumed() bool {
+	return s.has(StatusAppliedPreviously)
+}
+
+// has returns true if any step has the given status
+func (s Summary) has(status Status) bool {
+	for _, step := range s.Steps {
+		if step.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emit emits the event for the upgrade being run
+func (e Engine) emit(event events.Event) {
+	event.Upgrade = e.upgrade
+
+	e.events.Emit(event)
+}
+
+// emitStep emits an event about the step at the given index. The status is only set for finished steps.
+func (e Engine) emitStep(eventType events.Type, summary Summary, index int, err error) {
+	event := events.Event{
+		Type:       eventType,
+		Step:       summary.Steps[index].Name,
+		StepNumber: index + 1,
+		StepCount:  len(summary.Steps),
+	}
+
+	if eventType == events.TypeStepFinished {
+		event.Status = string(summary.Steps[index].Status)
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	e.emit(event)
+}
+
+// emitCompleted emits the result of the run
+func (e Engine) emitCompleted(summary Summary, err error) {
+	event := events.Event{
+		Type:   events.TypeCompleted,
+		Result: result(summary, err),
+		Steps:  make([]events.StepResult, len(summary.Steps)),
+	}
+
+	for i, step := range summary.Steps {
+		event.Steps[i] = events.StepResult{Name: step.Name, Status: string(step.Status)}
+	}
+
+	// Aborting isn't an error, the user chose not to continue
+	if err != nil && event.Result != events.ResultAborted {
+		event.Error = err.Error()
+	}
+
+	e.emit(event)
+}
+
+func result(summary Summary, err error) events.Result {
+	switch {
+	case errors.Is(err, commonerrors.ErrUserAborted):
+		return events.ResultAborted
+	case err != nil:
+		return events.ResultFailed
+	case summary.has(StatusApplied):
+		return events.ResultApplied
+	case summary.has(StatusSimulated):
+		return events.ResultSimulated
+	default:
+		return events.ResultSkipped
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/events"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/prompt"
+)
+
+func TestEvents(t *testing.T) {
+	skip := func() error { return commonerrors.ErrNothingToDo }
+	fail := func(_ *Undo) error { return errors.New("boom") }
+	succeed := func(_ *Undo) error { return nil }
+
+	testCases := []struct {
+		name    string
+		opts    Opts
+		answers map[string]interface{}
+		steps   []Step
+		expect  []string
+	}{
+		{
+			name: "Should report every step and the result",
+			opts: Opts{Confirm: true},
+			steps: []Step{
+				{Name: "first", Apply: succeed, Preflight: skip},
+				{Name: "second", Apply: succeed},
+			},
+			expect: []string{
+				"step_finished first 1/2 skipped",
+				"step_started second 2/2",
+				"step_finished second 2/2 applied",
+				"completed applied",
+			},
+		},
+		{
+			name:  "Should report simulated steps in dry-run mode",
+			opts:  Opts{DryRun: true},
+			steps: []Step{{Name: "first", Apply: succeed}},
+			expect: []string{
+				"step_started first 1/1",
+				"step_finished first 1/1 simulated",
+				"completed simulated",
+			},
+		},
+		{
+			name:  "Should report when there is nothing to do",
+			opts:  Opts{Confirm: true},
+			steps: []Step{{Name: "first", Apply: succeed, Preflight: skip}},
+			expect: []string{
+				"nothing_to_do",
+				"completed skipped",
+			},
+		},
+		{
+			name:    "Should report prompts and aborts",
+			answers: map[string]interface{}{QuestionContinue.ID: false},
+			steps:   []Step{{Name: "first", Apply: succeed}},
+			expect: []string{
+				"prompt_needed Do you want to continue? continue",
+				"completed aborted",
+			},
+		},
+		{
+			name:  "Should report failures",
+			opts:  Opts{Confirm: true},
+			steps: []Step{{Name: "first", Apply: fail}},
+			expect: []string{
+				"step_started first 1/1",
+				"step_finished first 1/1 failed boom",
+				"completed failed applying step first: boom",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			tc.opts.Events = events.New(out)
+			tc.opts.Prompter = prompt.NewTest(t, tc.answers)
+
+			e := New(logger.New(logger.Error), tc.opts)
+
+			_, _ = e.Run(Upgrade{Name: "test", Steps: tc.steps})
+
+			var got []string
+
+			scanner := bufio.NewScanner(out)
+			for scanner.Scan() {
+				var event events.Event
+
+				err := json.Unmarshal(scanner.Bytes(), &event)
+				if err != nil {
+					t.Fatalf("parsing event: %s", err)
+				}
+
+				if event.Upgrade != "test" || event.Version != events.Version {
+					t.Errorf("expected upgrade test and version %d, got %s and %d", events.Version, event.Upgrade,
+						event.Version)
+				}
+
+				got = append(got, describe(event))
+			}
+
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("expected events\n%v\ngot\n%v", tc.expect, got)
+			}
+		})
+	}
+}
+
+// describe returns the parts of the event the test cares about
+func describe(event events.Event) string {
+	s := string(event.Type)
+
+	if event.Step != "" {
+		s += fmt.Sprintf(" %s %d/%d", event.Step, event.StepNumber, event.StepCount)
+	}
+
+	for _, part := range []string{event.Status, event.Message, event.Question, string(event.Result), event.Error} {
+		if part != "" {
+			s += " " + part
+		}
+	}
+
+	return s
+}
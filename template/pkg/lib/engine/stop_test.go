@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+)
+
+func TestStop(t *testing.T) {
+	stop := make(chan struct{})
+
+	var calls []string
+
+	e := New(logger.New(logger.Error), Opts{Confirm: true, Stop: stop})
+
+	summary, err := e.Run(Upgrade{Name: "test", Steps: []Step{
+		{
+			Name: "first",
+			Apply: func(_ *Undo) error {
+				calls = append(calls, "apply first")
+
+				// Stopped while the step runs, which must still finish
+				close(stop)
+
+				return nil
+			},
+			Rollback: func() error {
+				calls = append(calls, "roll back first")
+
+				return nil
+			},
+		},
+		{
+			Name: "second",
+			Apply: func(_ *Undo) error {
+				calls = append(calls, "apply second")
+
+				return nil
+			},
+		},
+	}})
+
+	var applyErr ApplyError
+	if !errors.Is(err, ErrStopped) || !errors.As(err, &applyErr) || !applyErr.RolledBack {
+		t.Fatalf("expected a rolled back ApplyError wrapping %v, got %v", ErrStopped, err)
+	}
+
+	expectCalls := []string{"apply first", "roll back first"}
+	if !reflect.DeepEqual(calls, expectCalls) {
+		t.Errorf("expected calls %v, got %v", expectCalls, calls)
+	}
+
+	expectStatus := []Status{StatusRolledBack, StatusPending}
+	for i, step := range summary.Steps {
+		if step.Status != expectStatus[i] {
+			t.Errorf("expected step %s to be %s, got %s", step.Name, expectStatus[i], step.Status)
+		}
+	}
+}
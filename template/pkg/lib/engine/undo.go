@@ -0,0 +1,30 @@
+package engine
+
+// Undo records actions that revert the changes made by steps. If a step fails, the recorded actions are run in the
+// reverse order of how they were recorded.
+type Undo struct {
+	currentStep int
+	actions     []undoAction
+}
+
+type undoAction struct {
+	step        int
+	description string
+	fn          func() error
+}
+
+// Add records an action that reverts a change. Record the action before making the change, so that a change that
+// fails halfway can be reverted as well.
+func (u *Undo) Add(description string, fn func() error) {
+	u.actions = append(u.actions, undoAction{
+		step:        u.currentStep,
+		description: description,
+		fn:          fn,
+	})
+}
+
+// isLastActionForStep returns true if the action at the given index is the first one recorded by its step, that
+// is, the last one to be run when rolling back
+func (u *Undo) isLastActionForStep(index int) bool {
+	return index == 0 || u.actions[index-1].step != u.actions[index].step
+}
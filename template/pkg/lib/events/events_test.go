@@ -0,0 +1,61 @@
+package events
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEmit(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	e := New(out)
+	e.now = func() time.Time {
+		return time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	}
+
+	e.Emit(Event{Type: TypeStepStarted, Upgrade: "ArgoCD", Step: "delete-release", StepNumber: 1, StepCount: 3})
+
+	expect := `{"version":1,"time":"2022-03-04T05:06:07Z","type":"step_started","upgrade":"ArgoCD",` +
+		`"step":"delete-release","stepNumber":1,"stepCount":3}` + "\n"
+
+	if out.String() != expect {
+		t.Errorf("expected %s, got %s", expect, out.String())
+	}
+
+	var nilEmitter *Emitter
+
+	nilEmitter.Emit(Event{Type: TypeCompleted})
+}
+
+func TestOpen(t *testing.T) {
+	testCases := []struct {
+		name        string
+		target      string
+		expectNil   bool
+		expectError bool
+	}{
+		{name: "Should emit nothing by default", target: "", expectNil: true},
+		{name: "Should write JSON lines to stdout", target: "jsonl"},
+		{name: "Should write to an open file descriptor", target: "fd:2"},
+		{name: "Should fail on a closed file descriptor", target: "fd:1000", expectError: true},
+		{name: "Should fail on an invalid file descriptor", target: "fd:three", expectError: true},
+		{name: "Should fail on unknown targets", target: "xml", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := Open(tc.target)
+
+			if tc.expectError != (err != nil) {
+				t.Fatalf("expected error: %t, got %v", tc.expectError, err)
+			}
+
+			if !tc.expectError && tc.expectNil != (e == nil) {
+				t.Errorf("expected nil emitter: %t, got %v", tc.expectNil, e)
+			}
+		})
+	}
+}
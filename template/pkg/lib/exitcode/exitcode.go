@@ -0,0 +1,56 @@
+// Package exitcode maps the error an upgrade ends with to the exit code okctl and scripts see. The codes are
+// documented in the README, and must not change meaning.
+package exitcode
+
+import (
+	"errors"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+)
+
+const (
+	// Applied means the upgrade completed. It is also used when there was nothing to do, unless skipped upgrades
+	// are reported with --report-skipped.
+	Applied = 0
+	// Failed means the upgrade failed for any other reason, such as invalid flags or an unreachable cluster
+	Failed = 1
+	// Aborted means the user answered no when asked to continue. Nothing has been changed.
+	Aborted = 2
+	// Skipped means there was nothing to do, as the upgrade doesn't apply to the cluster or has been applied already
+	Skipped = 3
+	// PreflightFailed means a preflight check failed, or the cluster no longer matches a saved plan. Nothing has been
+	// changed.
+	PreflightFailed = 4
+	// RolledBack means a step failed, and its changes, and those of earlier steps, have been rolled back
+	RolledBack = 5
+	// NotRolledBack means a step failed, and rollback was disabled or failed, or steps applied by an interrupted earlier
+	// run couldn't be rolled back. The cluster may be half upgraded.
+	NotRolledBack = 6
+)
+
+// For returns the exit code for the error returned by the upgrade's root command
+func For(err error) int {
+	var preflightErr engine.PreflightError
+
+	var applyErr engine.ApplyError
+
+	switch {
+	case err == nil:
+		return Applied
+	case errors.Is(err, commonerrors.ErrUserAborted):
+		return Aborted
+	case errors.Is(err, commonerrors.ErrNothingToDo):
+		return Skipped
+	case errors.As(err, &preflightErr):
+		return PreflightFailed
+	case errors.As(err, &applyErr):
+		if applyErr.RolledBack {
+			return RolledBack
+		}
+
+		return NotRolledBack
+	default:
+		return Failed
+	}
+}
@@ -0,0 +1,48 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+)
+
+func TestFor(t *testing.T) {
+	testCases := []struct {
+		name   string
+		err    error
+		expect int
+	}{
+		{name: "Should exit with 0 when applied", err: nil, expect: Applied},
+		{name: "Should exit with 1 on other errors", err: errors.New("no KUBECONFIG"), expect: Failed},
+		{name: "Should exit with 2 when aborted", err: commonerrors.ErrUserAborted, expect: Aborted},
+		{name: "Should exit with 3 when skipped", err: commonerrors.ErrNothingToDo, expect: Skipped},
+		{
+			name:   "Should exit with 4 when a preflight check fails",
+			err:    fmt.Errorf("running upgrade: %w", engine.PreflightError{Err: commonerrors.ErrPlanOutdated}),
+			expect: PreflightFailed,
+		},
+		{
+			name:   "Should exit with 5 when rolled back",
+			err:    engine.ApplyError{Err: errors.New("boom"), RolledBack: true},
+			expect: RolledBack,
+		},
+		{
+			name:   "Should exit with 6 when not rolled back",
+			err:    engine.ApplyError{Err: errors.New("boom")},
+			expect: NotRolledBack,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			if got := For(tc.err); got != tc.expect {
+				t.Errorf("expected %d, got %d", tc.expect, got)
+			}
+		})
+	}
+}
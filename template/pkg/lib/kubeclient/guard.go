@@ -0,0 +1,86 @@
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// accessReviewPath is where SelfSubjectAccessReviews are created, see pkg/lib/rbac
+const accessReviewPath = "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews"
+
+// ErrBlocked is returned for requests that would have changed the cluster in dry-run mode
+var ErrBlocked = errors.New("refusing to change the cluster in dry-run mode")
+
+// guard refuses requests that would change the cluster, so that a missed dry-run check in an upgrade can't make
+// changes. It records the refused requests, so they can be shown in the plan.
+type guard struct {
+	lock    sync.Mutex
+	blocked []string
+}
+
+func (g *guard) wrap(next http.RoundTripper) http.RoundTripper {
+	return guardedTransport{
+		guard: g,
+		next:  next,
+	}
+}
+
+func (g *guard) add(request string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.blocked = append(g.blocked, request)
+}
+
+func (g *guard) list() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return append([]string(nil), g.blocked...)
+}
+
+type guardedTransport struct {
+	guard *guard
+	next  http.RoundTripper
+}
+
+func (t guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isMutating(req) || isServerSideDryRun(req) || isAccessReview(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	request := fmt.Sprintf("%s %s", req.Method, req.URL.Path)
+
+	t.guard.add(request)
+
+	return nil, fmt.Errorf("%s: %w", request, ErrBlocked)
+}
+
+func isMutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
+
+func isServerSideDryRun(req *http.Request) bool {
+	for _, value := range req.URL.Query()["dryRun"] {
+		if value == metav1.DryRunAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAccessReview is true for requests asking whether the user is allowed to do something, which change nothing
+func isAccessReview(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, accessReviewPath)
+}
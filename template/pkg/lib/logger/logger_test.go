@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/redact"
+)
+
+func fixedClock() time.Time {
+	return time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+}
+
+func TestText(t *testing.T) {
+	testCases := []struct {
+		name   string
+		level  Level
+		log    func(l Logger)
+		expect string
+	}{
+		{
+			name:   "Should add a newline to Debugf",
+			level:  Debug,
+			log:    func(l Logger) { l.Debugf("found %d things", 2) },
+			expect: "[DEBUG] found 2 things\n",
+		},
+		{
+			name:   "Should not add a second newline",
+			level:  Info,
+			log:    func(l Logger) { l.Infof("Upgrading %s\n", "argocd") },
+			expect: "Upgrading argocd\n",
+		},
+		{
+			name:   "Should keep blank lines in multi-line messages",
+			level:  Info,
+			log:    func(l Logger) { l.Infof("\n\nWARNING\n\n") },
+			expect: "\n\nWARNING\n\n",
+		},
+		{
+			name:   "Should leave out debug messages at info level",
+			level:  Info,
+			log:    func(l Logger) { l.Debug("hidden") },
+			expect: "",
+		},
+		{
+			name:   "Should leave out fields",
+			level:  Info,
+			log:    func(l Logger) { l.With("step", "patch").Info("Running step") },
+			expect: "Running step\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			tc.log(NewWithOutput(tc.level, &out))
+
+			if out.String() != tc.expect {
+				t.Errorf("expected %q, got %q", tc.expect, out.String())
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+
+	l := NewWithOpts(Opts{Level: Info, Format: FormatJSON, Out: &out})
+	l.now = fixedClock
+
+	l = l.With("upgrade", "argocd", "step", "delete release")
+	l.With("step", "install release", "resource", "argocd/argocd-server", "error", errors.New("boom")).
+		Infof("Running step\n")
+	l.Debug("hidden")
+	l.Info("Done")
+
+	expect := `{"time":"2022-03-04T05:06:07Z","level":"info","msg":"Running step","upgrade":"argocd",` +
+		`"step":"install release","resource":"argocd/argocd-server","error":"boom"}` + "\n" +
+		`{"time":"2022-03-04T05:06:07Z","level":"info","msg":"Done","upgrade":"argocd","step":"delete release"}` + "\n"
+
+	if out.String() != expect {
+		t.Errorf("expected %q, got %q", expect, out.String())
+	}
+}
+
+func TestFile(t *testing.T) {
+	var out, file bytes.Buffer
+
+	l := NewWithOpts(Opts{Level: Info, Out: &out, File: &file})
+	l.now = fixedClock
+
+	l.Debug("Identifying relevant container")
+	l.Info("Applying patch")
+
+	if out.String() != "Applying patch\n" {
+		t.Errorf("expected only the info message on out, got %q", out.String())
+	}
+
+	expect := `{"time":"2022-03-04T05:06:07Z","level":"debug","msg":"Identifying relevant container"}` + "\n" +
+		`{"time":"2022-03-04T05:06:07Z","level":"info","msg":"Applying patch"}` + "\n"
+
+	if file.String() != expect {
+		t.Errorf("expected %q, got %q", expect, file.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	if err != nil || format != FormatJSON {
+		t.Errorf("expected %s, got %s (error: %v)", FormatJSON, format, err)
+	}
+
+	_, err = ParseFormat("xml")
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var out bytes.Buffer
+
+	redact.Register("s3cr3t-value")
+
+	l := NewWithOpts(Opts{Level: Info, Format: FormatJSON, Out: &out})
+	l.now = fixedClock
+
+	l.With("secret", "s3cr3t-value").Infof("Creating secret with value %s\n", "s3cr3t-value")
+
+	expect := `{"time":"2022-03-04T05:06:07Z","level":"info","msg":"Creating secret with value [redacted]",` +
+		`"secret":"[redacted]"}` + "\n"
+
+	if out.String() != expect {
+		t.Errorf("expected %q, got %q", expect, out.String())
+	}
+}
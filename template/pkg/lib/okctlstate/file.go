@@ -0,0 +1,81 @@
+package okctlstate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/codec/json"
+	"github.com/asdine/storm/v3/index"
+)
+
+// EnvHome is the environment variable okctl reads the directory containing .okctl from, if set
+const EnvHome = "OKCTL_HOME"
+
+// File is okctl's local state database, for upgrades that don't depend on okctl itself. Like okctl, it opens the
+// database for every call, so that the database isn't kept locked while the upgrade runs.
+type File struct {
+	path string
+}
+
+func (f File) All(to interface{}, options ...func(*index.Options)) error {
+	return f.with(func(node storm.Node) error {
+		return node.All(to, options...)
+	})
+}
+
+func (f File) Save(data interface{}) error {
+	return f.with(func(node storm.Node) error {
+		return node.Save(data)
+	})
+}
+
+func (f File) DeleteStruct(data interface{}) error {
+	return f.with(func(node storm.Node) error {
+		return node.DeleteStruct(data)
+	})
+}
+
+func (f File) with(fn func(node storm.Node) error) error {
+	// The same codec and file mode as okctl, see okctl's pkg/breeze
+	db, err := storm.Open(f.path, storm.Codec(json.Codec), storm.BoltOptions(0o600, nil))
+	if err != nil {
+		return fmt.Errorf("opening local state: %w", err)
+	}
+
+	defer func() {
+		_ = db.Close()
+	}()
+
+	return fn(db.From(NodeName))
+}
+
+// OpenFile returns okctl's local state database at the given path. The database must exist, as opening a missing
+// one would create an empty state.
+func OpenFile(path string) (File, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return File{}, fmt.Errorf("finding local state: %w", err)
+	}
+
+	return File{
+		path: path,
+	}, nil
+}
+
+// LocalStatePath returns where okctl keeps the local state database of the cluster with the given name
+func LocalStatePath(clusterName string) (string, error) {
+	home := os.Getenv(EnvHome)
+
+	if home == "" {
+		var err error
+
+		home, err = os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("acquiring home directory: %w", err)
+		}
+	}
+
+	return filepath.Join(home, ".okctl", "localState", clusterName, "state.db"), nil
+}
@@ -0,0 +1,64 @@
+// Package prompt asks the user questions. Upgrades get a Prompter instead of calling survey directly, so that
+// questions can be answered from a file in CI, see Scripted, and by tests, see Test.
+package prompt
+
+import (
+	"errors"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// ErrNoAnswer is returned when a question can't be answered without a terminal
+var ErrNoAnswer = errors.New("no answer")
+
+// Question is a question to the user
+type Question struct {
+	// ID identifies the question in answers files and events, for instance "continue". It must not change between
+	// releases of an upgrade, as pipelines depend on it.
+	ID string
+
+	// Message is the question shown to the user
+	Message string
+}
+
+// Prompter asks the user questions
+type Prompter interface {
+	// Confirm returns true if the user answers yes
+	Confirm(q Question) (bool, error)
+
+	// Input returns the text the user types
+	Input(q Question) (string, error)
+}
+
+// Survey asks the user in the terminal
+type Survey struct{}
+
+// Confirm asks the user in the terminal, defaulting to no
+func (Survey) Confirm(q Question) (bool, error) {
+	answer := false
+	prompt := &survey.Confirm{
+		Message: q.Message,
+	}
+
+	err := survey.AskOne(prompt, &answer)
+	if err != nil {
+		return false, err
+	}
+
+	return answer, nil
+}
+
+// Input asks the user to type an answer in the terminal
+func (Survey) Input(q Question) (string, error) {
+	answer := ""
+	prompt := &survey.Input{
+		Message: q.Message,
+	}
+
+	err := survey.AskOne(prompt, &answer)
+	if err != nil {
+		return "", err
+	}
+
+	return answer, nil
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestScripted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+
+	err := ioutil.WriteFile(path, []byte("continue: true\ndelete-logs: false\nrollback: maybe\n"), 0o600)
+	if err != nil {
+		t.Fatalf("writing answers file: %s", err)
+	}
+
+	prompter, err := LoadAnswers(path)
+	if err != nil {
+		t.Fatalf("loading answers: %s", err)
+	}
+
+	testCases := []struct {
+		name           string
+		id             string
+		expect         bool
+		expectError    bool
+		expectNoAnswer bool
+	}{
+		{name: "Should answer yes", id: "continue", expect: true},
+		{name: "Should answer no", id: "delete-logs", expect: false},
+		{name: "Should fail on questions missing from the file", id: "other", expectError: true, expectNoAnswer: true},
+		{name: "Should fail on answers that aren't true or false", id: "rollback", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			answer, err := prompter.Confirm(Question{ID: tc.id, Message: "Do you want to continue?"})
+
+			if tc.expectError != (err != nil) {
+				t.Fatalf("expected error: %t, got %v", tc.expectError, err)
+			}
+
+			if tc.expectNoAnswer != errors.Is(err, ErrNoAnswer) {
+				t.Errorf("expected error %v, got %v", ErrNoAnswer, err)
+			}
+
+			if answer != tc.expect {
+				t.Errorf("expected answer %t, got %t", tc.expect, answer)
+			}
+		})
+	}
+}
+
+func TestLoadAnswersFailsOnMissingFile(t *testing.T) {
+	_, err := LoadAnswers(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Error("expected an error for a missing answers file")
+	}
+}
+
+func TestScriptedInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+
+	err := ioutil.WriteFile(path, []byte("cluster-name: my-cluster\ncontinue: true\n"), 0o600)
+	if err != nil {
+		t.Fatalf("writing answers file: %s", err)
+	}
+
+	prompter, err := LoadAnswers(path)
+	if err != nil {
+		t.Fatalf("loading answers: %s", err)
+	}
+
+	answer, err := prompter.Input(Question{ID: "cluster-name"})
+	if err != nil || answer != "my-cluster" {
+		t.Errorf("expected answer my-cluster, got %s and error %v", answer, err)
+	}
+
+	_, err = prompter.Input(Question{ID: "continue"})
+	if err == nil {
+		t.Error("expected an error when the answer isn't text")
+	}
+
+	_, err = prompter.Input(Question{ID: "other"})
+	if !errors.Is(err, ErrNoAnswer) {
+		t.Errorf("expected error %v, got %v", ErrNoAnswer, err)
+	}
+}
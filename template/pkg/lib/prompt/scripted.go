@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Scripted answers questions from an answers file, so that pipelines can approve specific questions instead of
+// every question with --confirm. The file maps question IDs to answers, which are true or false for yes/no
+// questions, and text for questions where the user types the answer:
+//
+//	continue: true
+//	cluster-name: my-cluster
+type Scripted struct {
+	answers map[string]interface{}
+}
+
+// LoadAnswers reads an answers file
+func LoadAnswers(path string) (Scripted, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Scripted{}, fmt.Errorf("reading answers file: %w", err)
+	}
+
+	var answers map[string]interface{}
+
+	err = yaml.Unmarshal(raw, &answers)
+	if err != nil {
+		return Scripted{}, fmt.Errorf("unmarshalling answers file %s: %w", path, err)
+	}
+
+	return Scripted{answers: answers}, nil
+}
+
+// Confirm returns the answer from the file. Questions missing from the file fail with ErrNoAnswer, as nobody is
+// there to answer them.
+func (s Scripted) Confirm(q Question) (bool, error) {
+	value, ok := s.answers[q.ID]
+	if !ok {
+		return false, fmt.Errorf("%w to question %s in answers file: %s", ErrNoAnswer, q.ID, q.Message)
+	}
+
+	answer, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected true or false as the answer to question %s, got %v", q.ID, value)
+	}
+
+	return answer, nil
+}
+
+// Input returns the answer from the file, which must be text
+func (s Scripted) Input(q Question) (string, error) {
+	value, ok := s.answers[q.ID]
+	if !ok {
+		return "", fmt.Errorf("%w to question %s in answers file: %s", ErrNoAnswer, q.ID, q.Message)
+	}
+
+	answer, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected text as the answer to question %s, got %v", q.ID, value)
+	}
+
+	return answer, nil
+}
@@ -0,0 +1,98 @@
+// Package rbac checks that the user is allowed to make every request an upgrade needs before it changes anything, so
+// that an upgrade doesn't fail halfway because the user's role is missing a permission
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ErrMissingPermissions is returned when the user isn't allowed to make one or more of the requests
+var ErrMissingPermissions = errors.New("missing Kubernetes permissions")
+
+// Permission is a kind of request the user must be allowed to make, for instance deleting ingresses in argocd
+type Permission struct {
+	// Verb is the Kubernetes verb, for instance "get", "patch" or "delete"
+	Verb string
+
+	// Group is the API group of the resource, for instance "apps". Empty for the core group.
+	Group string
+
+	// Resource is the plural resource name, for instance "deployments"
+	Resource string
+
+	// Namespace is empty for cluster-scoped resources, or for all namespaces
+	Namespace string
+}
+
+func (p Permission) String() string {
+	resource := p.Resource
+	if p.Group != "" {
+		resource = fmt.Sprintf("%s.%s", p.Resource, p.Group)
+	}
+
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s %s", p.Verb, resource)
+	}
+
+	return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+}
+
+// Checker asks the cluster whether the user has permissions, with SelfSubjectAccessReviews
+type Checker struct {
+	client kubernetes.Interface
+}
+
+// Check returns ErrMissingPermissions, listing every permission the user is missing, if the user isn't allowed to
+// make all the requests
+func (c Checker) Check(permissions []Permission) error {
+	var missing []string
+
+	checked := make(map[Permission]bool, len(permissions))
+
+	for _, p := range permissions {
+		if checked[p] {
+			continue
+		}
+
+		checked[p] = true
+
+		review, err := c.client.AuthorizationV1().SelfSubjectAccessReviews().Create(context.Background(),
+			&authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace: p.Namespace,
+						Verb:      p.Verb,
+						Group:     p.Group,
+						Resource:  p.Resource,
+					},
+				},
+			}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("reviewing access to %s: %w", p, err)
+		}
+
+		if !review.Status.Allowed {
+			missing = append(missing, p.String())
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w:\n  %s", ErrMissingPermissions, strings.Join(missing, "\n  "))
+}
+
+// New returns a checker asking the cluster the client talks to
+func New(client kubernetes.Interface) Checker {
+	return Checker{
+		client: client,
+	}
+}
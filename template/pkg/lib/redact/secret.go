@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"encoding/json"
+)
+
+// secretFields are the fields of a Kubernetes Secret that hold its values
+var secretFields = []string{"data", "stringData"} //nolint:gochecknoglobals
+
+// SecretData returns the JSON body with the values of every Kubernetes Secret's data and stringData fields replaced
+// by Placeholder. Secrets are found by their kind, also inside lists and watch events. If isSecret is true, the body
+// is known to be about secrets, as with requests to a secrets path, so the top level object is treated as a Secret
+// even without a kind, and the values of JSON patch operations are replaced too.
+//
+// ok is false if the body isn't JSON. The body is returned unchanged if there was nothing to replace.
+func SecretData(body []byte, isSecret bool) (redacted []byte, ok bool) {
+	var doc interface{}
+
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		return body, false
+	}
+
+	changed := false
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		changed = redactObject(v, isSecret)
+	case []interface{}:
+		if isSecret {
+			changed = redactPatch(v)
+		} else {
+			changed = redactValue(v)
+		}
+	}
+
+	if !changed {
+		return body, true
+	}
+
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return body, false
+	}
+
+	return raw, true
+}
+
+// redactObject replaces the values of obj if it is a Secret, and looks for Secrets in its fields
+func redactObject(obj map[string]interface{}, isSecret bool) bool {
+	kind, _ := obj["kind"].(string)
+
+	changed := false
+
+	if isSecret || kind == "Secret" {
+		for _, field := range secretFields {
+			data, ok := obj[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for key := range data {
+				data[key] = Placeholder
+				changed = true
+			}
+		}
+	}
+
+	// The items of a list don't have a kind of their own
+	if kind == "SecretList" || (isSecret && kind == "") {
+		if items, ok := obj["items"].([]interface{}); ok {
+			for _, item := range items {
+				if itemObj, ok := item.(map[string]interface{}); ok {
+					changed = redactObject(itemObj, true) || changed
+				}
+			}
+		}
+	}
+
+	// Watch events and other wrappers contain Secrets further down
+	for _, value := range obj {
+		changed = redactValue(value) || changed
+	}
+
+	return changed
+}
+
+// redactValue looks for Secrets in the value
+func redactValue(value interface{}) bool {
+	changed := false
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		changed = redactObject(v, false)
+	case []interface{}:
+		for _, item := range v {
+			changed = redactValue(item) || changed
+		}
+	}
+
+	return changed
+}
+
+// redactPatch replaces the values of the operations in a JSON patch
+func redactPatch(operations []interface{}) bool {
+	changed := false
+
+	for _, operation := range operations {
+		op, ok := operation.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := op["value"]; ok {
+			op["value"] = Placeholder
+			changed = true
+		}
+	}
+
+	return changed
+}
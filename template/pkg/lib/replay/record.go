@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Recorder records every request that reaches the cluster. Install WrapTransport with rest.Config.Wrap, and call
+// Save when the upgrade is done. A nil recorder records nothing.
+//
+// Cassettes contain request and response bodies as they are, so don't record upgrades that read or write secrets.
+type Recorder struct {
+	lock     sync.Mutex
+	path     string
+	cassette Cassette
+}
+
+// NewRecorder returns a recorder that saves to the given path. It returns nil if the path is empty.
+func NewRecorder(path string) *Recorder {
+	if path == "" {
+		return nil
+	}
+
+	return &Recorder{path: path}
+}
+
+// WrapTransport returns a round tripper that records every request and response
+func (r *Recorder) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if r == nil {
+		return next
+	}
+
+	return recordingTransport{
+		recorder: r,
+		next:     next,
+	}
+}
+
+// Save writes the recorded interactions to the cassette file
+func (r *Recorder) Save() error {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.cassette.Save(r.path)
+}
+
+func (r *Recorder) add(interaction Interaction) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+}
+
+type recordingTransport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	if err != nil {
+		return resp, err
+	}
+
+	t.recorder.add(Interaction{
+		Request: Request{
+			Method: req.Method,
+			Path:   req.URL.RequestURI(),
+			Body:   toBody(body),
+		},
+		Response: Response{
+			Status: resp.StatusCode,
+			Body:   toBody(respBody),
+		},
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,121 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "%s"}}`, r.Method)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(cassettePath)
+
+	cfg := &rest.Config{Host: server.URL}
+	cfg.Wrap(recorder.WrapTransport)
+
+	recording, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+
+	expected := useConfigMaps(t, recording)
+
+	err = recorder.Save()
+	if err != nil {
+		t.Fatalf("saving cassette: %s", err)
+	}
+
+	server.Close()
+
+	replayed := useConfigMaps(t, NewClient(t, cassettePath))
+
+	if replayed != expected {
+		t.Errorf("expected replayed responses %s, got %s", expected, replayed)
+	}
+}
+
+func TestReplayFailsOnUnexpectedRequests(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+
+	err := Cassette{
+		Interactions: []Interaction{
+			{
+				Request:  Request{Method: http.MethodGet, Path: "/api/v1/namespaces/default/configmaps/expected"},
+				Response: Response{Status: http.StatusOK, Body: []byte(`{"metadata": {"name": "expected"}}`)},
+			},
+		},
+	}.Save(cassettePath)
+	if err != nil {
+		t.Fatalf("saving cassette: %s", err)
+	}
+
+	fake := &fakeT{T: t}
+	client := NewClient(fake, cassettePath)
+
+	_, err = client.CoreV1().ConfigMaps("default").Get(context.Background(), "unexpected", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("expected an error for a request not in the cassette")
+	}
+
+	fake.runCleanups()
+
+	if len(fake.errors) != 2 {
+		t.Errorf("expected the unexpected request and the unused interaction to be reported, got %v", fake.errors)
+	}
+}
+
+// useConfigMaps makes a read and a write, and returns the names in the responses
+func useConfigMaps(t *testing.T, client kubernetes.Interface) string {
+	t.Helper()
+
+	got, err := client.CoreV1().ConfigMaps("default").Get(context.Background(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting config map: %s", err)
+	}
+
+	created, err := client.CoreV1().ConfigMaps("default").Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Data:       map[string]string{"key": "value"},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("creating config map: %s", err)
+	}
+
+	return got.Name + " " + created.Name
+}
+
+// fakeT records errors instead of failing the test, and runs cleanups when asked to
+type fakeT struct {
+	*testing.T
+	errors   []string
+	cleanups []func()
+}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Cleanup(fn func()) {
+	f.cleanups = append(f.cleanups, fn)
+}
+
+func (f *fakeT) runCleanups() {
+	for _, fn := range f.cleanups {
+		fn()
+	}
+}
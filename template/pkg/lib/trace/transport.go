@@ -0,0 +1,129 @@
+package trace
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/redact"
+)
+
+// maxBodySize is the number of bytes of each request and response body that is recorded
+const maxBodySize = 64 * 1024
+
+// WrapTransport returns a round tripper that records every request and response. Install it with rest.Config.Wrap.
+func (r *Recorder) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if r == nil {
+		return next
+	}
+
+	return transport{
+		recorder: r,
+		next:     next,
+	}
+}
+
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	entry := Entry{
+		Time:   time.Now(),
+		Kind:   KindKubernetes,
+		Method: req.Method,
+		Target: req.URL.RequestURI(),
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Request = redactBody(req, body)
+
+	resp, err := t.next.RoundTrip(req)
+
+	entry.LatencyMs = time.Since(entry.Time).Milliseconds()
+
+	if err != nil {
+		entry.Error = err.Error()
+		t.recorder.Record(entry)
+
+		return nil, err
+	}
+
+	entry.Status = resp.StatusCode
+
+	// Watches stream until they are closed, so their bodies can't be read here
+	if req.URL.Query().Get("watch") != "true" {
+		body, err = readResponseBody(resp)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+
+		entry.Response = redactBody(req, body)
+	}
+
+	t.recorder.Record(entry)
+
+	return resp, err
+}
+
+// readRequestBody returns the request body, and replaces it so it can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// readResponseBody returns the response body, and replaces it so the client can still read it
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	if resp.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, err
+}
+
+// redactBody replaces the values of Kubernetes Secrets and registered sensitive values in the body, and truncates large
+// bodies. Bodies of requests for secrets that aren't JSON are left out entirely.
+func redactBody(req *http.Request, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	isSecret := strings.Contains(req.URL.Path, "/secrets")
+
+	body, ok := redact.SecretData(body, isSecret)
+	if !ok && isSecret {
+		return redact.Placeholder
+	}
+
+	// Redacted before truncating, so that a value cut in half is still replaced
+	s := redact.String(string(body))
+
+	if len(s) > maxBodySize {
+		return s[:maxBodySize] + "...(truncated)"
+	}
+
+	return s
+}
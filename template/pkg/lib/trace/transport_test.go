@@ -0,0 +1,130 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/redact"
+)
+
+func TestTransport(t *testing.T) {
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		response       string
+		expectRequest  string
+		expectResponse string
+	}{
+		{
+			name:           "Should record request and response bodies",
+			method:         http.MethodPatch,
+			path:           "/apis/apps/v1/namespaces/monitoring/deployments/grafana",
+			body:           `[{"op": "replace"}]`,
+			response:       `{"kind": "Status"}`,
+			expectRequest:  `[{"op": "replace"}]`,
+			expectResponse: `{"kind": "Status"}`,
+		},
+		{
+			name:           "Should redact secret data",
+			method:         http.MethodPost,
+			path:           "/api/v1/namespaces/argocd/secrets",
+			body:           `{"data": {"password": "c2VjcmV0"}}`,
+			response:       `{"kind": "Secret", "metadata": {"name": "argocd-secret"}, "data": {"password": "c2VjcmV0"}}`,
+			expectRequest:  `{"data":{"password":"[redacted]"}}`,
+			expectResponse: `{"data":{"password":"[redacted]"},"kind":"Secret","metadata":{"name":"argocd-secret"}}`,
+		},
+		{
+			name:           "Should leave out secret bodies that aren't JSON",
+			method:         http.MethodPost,
+			path:           "/api/v1/namespaces/argocd/secrets",
+			body:           "password=secret",
+			response:       `{"kind": "Status"}`,
+			expectRequest:  redact.Placeholder,
+			expectResponse: `{"kind": "Status"}`,
+		},
+		{
+			name:           "Should redact registered values",
+			method:         http.MethodPut,
+			path:           "/api/v1/namespaces/argocd/configmaps/argocd-cm",
+			body:           `{"data": {"token": "registered-token"}}`,
+			response:       `{"kind": "Status", "message": "bad token registered-token"}`,
+			expectRequest:  `{"data": {"token": "[redacted]"}}`,
+			expectResponse: `{"kind": "Status", "message": "bad token [redacted]"}`,
+		},
+	}
+
+	redact.Register("registered-token")
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			out := &bytes.Buffer{}
+			client := &http.Client{Transport: New(out).WrapTransport(http.DefaultTransport)}
+
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("creating request: %s", err)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("sending request: %s", err)
+			}
+
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			var entries []Entry
+
+			scanner := bufio.NewScanner(out)
+			for scanner.Scan() {
+				var entry Entry
+
+				err = json.Unmarshal(scanner.Bytes(), &entry)
+				if err != nil {
+					t.Fatalf("parsing trace: %s", err)
+				}
+
+				entries = append(entries, entry)
+			}
+
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(entries))
+			}
+
+			entry := entries[0]
+
+			if entry.Kind != KindKubernetes || entry.Method != tc.method || entry.Target != tc.path {
+				t.Errorf("expected %s %s %s, got %s %s %s", KindKubernetes, tc.method, tc.path, entry.Kind,
+					entry.Method, entry.Target)
+			}
+
+			if entry.Status != http.StatusCreated {
+				t.Errorf("expected status %d, got %d", http.StatusCreated, entry.Status)
+			}
+
+			if entry.Request != tc.expectRequest {
+				t.Errorf("expected request body %s, got %s", tc.expectRequest, entry.Request)
+			}
+
+			if entry.Response != tc.expectResponse {
+				t.Errorf("expected response body %s, got %s", tc.expectResponse, entry.Response)
+			}
+		})
+	}
+}
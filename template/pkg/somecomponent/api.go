@@ -1,74 +1,189 @@
 package somecomponent
 
 import (
+	"context"
 	"fmt"
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/cmdflags"
+
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/declaration"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/info"
 	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/rbac"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// namespace is where the component runs
+	namespace      = "somenamespace"
+	deploymentName = "somecomponent"
+	logsClaimName  = "somecomponent-logs"
+
+	imageBeforeUpgrade = "somecomponent:0.5"
+	imageAfterUpgrade  = "somecomponent:0.6"
+)
+
+// logsWarning is shown before the logs are deleted, and in the upgrade's info
+const logsWarning = "This will delete all logs."
+
+// Info describes the upgrade for --info. TargetVersion and ID must match the name of the upgrade's directory.
+func Info() info.Info {
+	return info.Info{
+		TargetVersion: "0.0.60",
+		ID:            "some-component",
+		Components:    []string{"somecomponent"},
+		Destructive:   true,
+		RequiredEnv:   []string{"KUBECONFIG", declaration.EnvPath},
+		Warning:       logsWarning,
+	}
+}
+
 // SomeComponent is a sample okctl component
 type SomeComponent struct {
-	flags   cmdflags.Flags
-	log     logger.Logger
-	dryRun  bool
-	confirm bool
+	log       logger.Logger
+	clientSet kubernetes.Interface
 }
 
-// Upgrade upgrades the component
-func (c SomeComponent) Upgrade() error {
-	c.log.Info("Upgrading SomeComponent")
-
-	c.log.Debug("SomeComponent is on version 0.5. Updating to 0.6")
+// Upgrade returns the steps needed to upgrade the component
+func (c SomeComponent) Upgrade() engine.Upgrade {
+	return engine.Upgrade{
+		Name:          "SomeComponent",
+		Preflight:     c.preflight,
+		Preconditions: c.preconditions,
+		Steps: []engine.Step{
+			{
+				Name:        "delete-logs",
+				Preflight:   c.warnAboutLogs,
+				Plan:        c.planDeleteLogs,
+				Apply:       c.deleteLogs,
+				Destructive: true,
+				Permissions: []rbac.Permission{
+					{Verb: "delete", Resource: "persistentvolumeclaims", Namespace: namespace},
+				},
+			},
+			{
+				Name:   "update-component",
+				Plan:   c.planUpdate,
+				Apply:  c.update,
+				Verify: c.verify,
+				Permissions: []rbac.Permission{
+					{Verb: "get", Group: "apps", Resource: "deployments", Namespace: namespace},
+					{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: namespace},
+				},
+			},
+		},
+	}
+}
 
-	if !c.dryRun && !c.confirm {
-		c.log.Info("This will delete all logs.")
+func (c SomeComponent) preflight() error {
+	image, err := getImage(c.clientSet)
+	if kerrors.IsNotFound(err) {
+		c.log.Info("SomeComponent is not installed, not doing anything")
+		return commonerrors.ErrNothingToDo
+	}
 
-		answer, err := c.askUser("Do you want to continue?")
-		if err != nil {
-			return fmt.Errorf("prompting user: %w", err)
-		}
+	if err != nil {
+		return err
+	}
 
-		if !answer {
-			return commonerrors.ErrUserAborted
-		}
+	if image != imageBeforeUpgrade {
+		c.log.Infof("SomeComponent runs %s. This upgrade only targets %s. Ignoring upgrade.\n", image, imageBeforeUpgrade)
+		return commonerrors.ErrNothingToDo
 	}
 
-	if c.dryRun {
-		c.log.Info("Simulating some stuff")
-	} else {
-		c.log.Info("Doing some stuff")
+	c.log.Debugf("Updating SomeComponent to %s\n", imageAfterUpgrade)
+
+	return nil
+}
+
+// preconditions returns what a saved plan depends on. Applying the plan fails if any of these have changed.
+func (c SomeComponent) preconditions() (map[string]string, error) {
+	image, err := getImage(c.clientSet)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, err
 	}
 
-	c.log.Info("Upgrading SomeComponent done!")
+	return map[string]string{
+		"someComponentImage": image,
+	}, nil
+}
+
+func (c SomeComponent) warnAboutLogs() error {
+	c.log.Info(logsWarning)
 
 	return nil
 }
 
-func (c SomeComponent) askUser(question string) (bool, error) {
-	answer := false
-	prompt := &survey.Confirm{
-		Message: question,
+func (c SomeComponent) planDeleteLogs() ([]engine.Action, error) {
+	return []engine.Action{
+		{
+			Description: "delete all logs",
+			Resource:    fmt.Sprintf("persistentvolumeclaim %s/%s", namespace, logsClaimName),
+			Risk:        engine.RiskHigh,
+		},
+	}, nil
+}
+
+// deleteLogs deletes the volume claim with the logs. Deleted logs can't be restored, so there is no undo action.
+func (c SomeComponent) deleteLogs(_ *engine.Undo) error {
+	c.log.Info("Deleting logs")
+
+	err := c.clientSet.CoreV1().PersistentVolumeClaims(namespace).Delete(context.Background(), logsClaimName,
+		metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("deleting logs: %w", err)
 	}
 
-	err := survey.AskOne(prompt, &answer)
+	return nil
+}
+
+func (c SomeComponent) planUpdate() ([]engine.Action, error) {
+	return []engine.Action{
+		{
+			Description: "update image to version 0.6",
+			Resource:    fmt.Sprintf("deployment %s/%s", namespace, deploymentName),
+			Patch:       []map[string]string{imagePatch(imageAfterUpgrade)},
+			Risk:        engine.RiskMedium,
+		},
+	}, nil
+}
+
+func (c SomeComponent) update(undo *engine.Undo) error {
+	undo.Add(fmt.Sprintf("set image back to %s", imageBeforeUpgrade), func() error {
+		return setImage(c.clientSet, imageBeforeUpgrade)
+	})
+
+	c.log.Infof("Updating image to %s\n", imageAfterUpgrade)
+
+	return setImage(c.clientSet, imageAfterUpgrade)
+}
+
+func (c SomeComponent) verify() error {
+	image, err := getImage(c.clientSet)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	return answer, nil
+	if image != imageAfterUpgrade {
+		return fmt.Errorf("expected image %s, got %s", imageAfterUpgrade, image)
+	}
+
+	c.log.Debugf("SomeComponent runs %s\n", image)
+
+	return nil
 }
 
-type Opts struct {
-	DryRun  bool
-	Confirm bool
+// Access checks the user's permissions in the cluster the component runs in
+func (c SomeComponent) Access() rbac.Checker {
+	return rbac.New(c.clientSet)
 }
 
-func New(logger logger.Logger, opts Opts) SomeComponent {
+// New returns the component in the cluster the client talks to
+func New(logger logger.Logger, clientSet kubernetes.Interface) SomeComponent {
 	return SomeComponent{
-		log:     logger,
-		dryRun:  opts.DryRun,
-		confirm: opts.Confirm,
+		log:       logger,
+		clientSet: clientSet,
 	}
 }
@@ -0,0 +1,24 @@
+package somecomponent
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/idempotency"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/logger"
+)
+
+// Seed the fake cluster with a cluster the upgrade applies to, and pass it to the component
+func TestUpgradeIsIdempotent(t *testing.T) {
+	cluster := fakecluster.New(t, "testdata/somecomponent-0.5.yaml")
+
+	idempotency.Run(t, idempotency.Subject{
+		Cluster: cluster,
+		Upgrade: func() engine.Upgrade {
+			return New(logger.New(logger.Error), cluster.Clientset).Upgrade()
+		},
+	})
+
+	cluster.AssertContainerImage(namespace, deploymentName, deploymentName, imageAfterUpgrade)
+}
@@ -0,0 +1,48 @@
+package somecomponent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getImage returns the image of the component's container
+func getImage(clientSet kubernetes.Interface) (string, error) {
+	deployment, err := clientSet.AppsV1().Deployments(namespace).Get(context.Background(), deploymentName,
+		metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting deployment: %w", err)
+	}
+
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return "", fmt.Errorf("deployment %s has no containers", deploymentName)
+	}
+
+	return containers[0].Image, nil
+}
+
+// setImage patches the image of the component's container
+func setImage(clientSet kubernetes.Interface, image string) error {
+	raw, err := json.Marshal([]map[string]string{imagePatch(image)})
+	if err != nil {
+		return fmt.Errorf("marshalling patch: %w", err)
+	}
+
+	_, err = clientSet.AppsV1().Deployments(namespace).Patch(context.Background(), deploymentName,
+		types.JSONPatchType, raw, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("patching deployment: %w", err)
+	}
+
+	return nil
+}
+
+// imagePatch is a JSON patch operation that sets the image of the component's container
+func imagePatch(image string) map[string]string {
+	return map[string]string{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": image}
+}
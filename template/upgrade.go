@@ -1,21 +1,88 @@
 package main
 
 import (
-	"github.com/oslokommune/okctl-upgrade/template/pkg/somecomponent"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/kubeclient"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/template/pkg/lib/trace"
 )
 
-func upgrade(context Context, flags cmdFlags) error {
-	opts := somecomponent.Opts{
-		DryRun:  flags.dryRun,
-		Confirm: flags.confirm,
+func upgrade(context Context, flags cmdflags.Flags) error {
+	format, err := output.ParseFormat(flags.Output)
+	if err != nil {
+		return err
+	}
+
+	if format != output.FormatText && !flags.DryRun {
+		return errors.New("--output=json|yaml can only be used together with --dry-run")
+	}
+
+	recorder, err := trace.NewFile(flags.Trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	cassette := replay.NewRecorder(flags.Record)
+
+	clusterName, err := context.checkCluster()
+	if err != nil {
+		return err
+	}
+
+	c, err := newComponent(context, kubeclient.Opts{DryRun: flags.DryRun, Trace: recorder, Record: cassette})
+	if err != nil {
+		return err
+	}
+
+	record, err := newRecord(context)
+	if err != nil {
+		return err
 	}
 
-	c := somecomponent.New(context.logger, opts)
+	e := engine.New(context.logger, engine.Opts{
+		DryRun:         flags.DryRun,
+		Confirm:        flags.Confirm,
+		Record:         record,
+		NoRollback:     flags.NoRollback,
+		Prompter:       context.prompter,
+		Access:         c.Access(),
+		ClusterName:    clusterName,
+		AcceptDataLoss: flags.AcceptDataLoss,
+		Events:         context.events,
+		ReportSkipped:  flags.ReportSkipped,
+	})
+
+	if format != output.FormatText {
+		plan, err := e.Plan(c.Upgrade())
+		if err != nil {
+			return fmt.Errorf("planning upgrade: %w", err)
+		}
+
+		return output.Write(os.Stdout, format, plan)
+	}
+
+	_, err = e.Run(c.Upgrade())
+
+	// Save the recording also if the upgrade failed, as failures are worth replaying too
+	saveErr := cassette.Save()
 
-	err := c.Upgrade()
 	if err != nil {
 		return err
 	}
 
+	if saveErr != nil {
+		return fmt.Errorf("saving recording: %w", saveErr)
+	}
+
 	return nil
 }
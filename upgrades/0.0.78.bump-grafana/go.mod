@@ -5,7 +5,12 @@ go 1.16
 require (
 	github.com/AlecAivazis/survey/v2 v2.3.2
 	github.com/Masterminds/semver v1.5.0
+	github.com/asdine/storm/v3 v3.2.1
+	github.com/aws/aws-sdk-go v1.42.32
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
+	k8s.io/api v0.22.4
 	k8s.io/apimachinery v0.22.4
 	k8s.io/client-go v0.22.4
+	sigs.k8s.io/yaml v1.2.0
 )
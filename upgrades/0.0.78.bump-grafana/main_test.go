@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/grafana"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/contract"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func TestContract(t *testing.T) {
+	contract.Run(t, contract.Subject{
+		NewCommand: func(env contract.Env) *cobra.Command {
+			return buildRootCommand(Dependencies{
+				Prompter:    env.Prompter,
+				ClientSet:   env.ClientSet,
+				ClusterName: env.ClusterName,
+				Record:      env.Record,
+			})
+		},
+		ExitCode: exitcode.For,
+		Fixtures: []string{"pkg/grafana/testdata/grafana-7.3.5.yaml"},
+	})
+}
+
+func TestInfo(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %s", err)
+	}
+
+	// Upgrades are copied from the template, which isn't named after an upgrade
+	if filepath.Base(wd) == "template" {
+		t.Skip("the template is not an upgrade")
+	}
+
+	if name := grafana.Info().Name(); name != filepath.Base(wd) {
+		t.Errorf("expected the info to match the directory name %s, got %s", filepath.Base(wd), name)
+	}
+}
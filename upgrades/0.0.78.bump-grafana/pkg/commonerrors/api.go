@@ -1,5 +0,0 @@
-package commonerrors
-
-import "errors"
-
-var ErrUserAborted = errors.New("aborted by user")
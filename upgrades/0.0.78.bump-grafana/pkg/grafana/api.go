@@ -1,75 +1,152 @@
 package grafana
 
 import (
-	"errors"
 	"fmt"
-	"os"
 
-	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/declaration"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/events"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/info"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/kubeclient"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/rbac"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/trace"
+	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	// userDataWarning is shown before the upgrade runs, and in the upgrade's info
+	userDataWarning = "During this upgrade, Grafana needs to restart, and all user data stored in Grafana including " +
+		"dashboards, WILL be lost (unless you have added configuration to prevent this)!"
+
+	mitigationsURL = "https://www.okctl.io/new-upgrade-for-grafana-available"
+)
+
+// Info describes the upgrade for --info. TargetVersion and ID must match the name of the upgrade's directory.
+func Info() info.Info {
+	return info.Info{
+		TargetVersion: "0.0.78",
+		ID:            "bump-grafana",
+		Components:    []string{"grafana"},
+		Destructive:   true,
+		RequiredEnv:   []string{"KUBECONFIG", declaration.EnvPath},
+		Warning:       fmt.Sprintf("%s For more details and possible mitigations, see: %s", userDataWarning, mitigationsURL),
+	}
+}
+
 // Upgrader is a sample okctl component
 type Upgrader struct {
-	logger  logger.Logger
-	dryRun  bool
-	confirm bool
+	logger    logger.Logger
+	clientSet kubernetes.Interface
+	dryRun    bool
+	events    *events.Emitter
 }
 
-// Upgrade upgrades the component
-func (c Upgrader) Upgrade() error {
-	c.logger.Info("Upgrading Grafana")
-
-	kubeconfigPath := os.Getenv("KUBECONFIG")
-	if kubeconfigPath == "" {
-		return errors.New("missing required KUBECONFIG environment variable")
+// Upgrade returns the steps needed to upgrade Grafana
+func (c Upgrader) Upgrade() engine.Upgrade {
+	return engine.Upgrade{
+		Name:          upgradeName,
+		Preflight:     c.preflight,
+		Preconditions: c.preconditions,
+		Steps: []engine.Step{
+			{
+				Name:   "patch-grafana-deployment",
+				Plan:   c.planPatch,
+				Apply:  c.patch,
+				Verify: c.postflight,
+				// Dashboards stored in Grafana are lost
+				Destructive: true,
+				Permissions: []rbac.Permission{
+					{Verb: "get", Group: "apps", Resource: "deployments", Namespace: monitoringNamespace},
+					{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: monitoringNamespace},
+				},
+			},
+		},
 	}
+}
 
-	kubectlClient, err := acquireKubectlClient(kubeconfigPath)
+func (c Upgrader) planPatch() ([]engine.Action, error) {
+	patches, err := buildGrafanaPatch(c.logger, c.clientSet, targetGrafanaVersion)
 	if err != nil {
-		return fmt.Errorf("acquiring kubectl client: %w", err)
+		return nil, fmt.Errorf("building patch: %w", err)
 	}
 
-	if c.dryRun {
-		c.logger.Info("Simulating upgrade")
-	} else {
-		c.logger.Info("Patching Grafana")
-	}
+	return []engine.Action{
+		{
+			Description: fmt.Sprintf("update Grafana image to %s, which restarts Grafana and loses dashboards "+
+				"stored in Grafana", targetGrafanaVersion.String()),
+			Resource: fmt.Sprintf("deployment %s/%s", monitoringNamespace, grafanaDeploymentName),
+			Patch:    patches,
+			Risk:     engine.RiskHigh,
+		},
+	}, nil
+}
 
-	err = c.preflight(kubectlClient)
-	if err != nil {
-		if errors.Is(err, ErrNothingToDo) {
-			return nil
-		}
+func (c Upgrader) patch(undo *engine.Undo) error {
+	c.logger.Debug(fmt.Sprintf("Passed preflight test. Upgrading Grafana to %s", targetGrafanaVersion.String()))
 
-		return fmt.Errorf("running preflight checks: %w", err)
-	}
+	undo.Add(fmt.Sprintf("patch Grafana back to %s", expectedGrafanaVersionPreUpgrade.String()), func() error {
+		return patchGrafanaDeployment(c.logger, c.clientSet, expectedGrafanaVersionPreUpgrade)
+	})
 
-	c.logger.Debug(fmt.Sprintf("Passed preflight test. Upgrading Grafana to %s", targetGrafanaVersion.String()))
+	c.logger.Info("Patching Grafana")
 
-	err = patchGrafanaDeployment(c.logger, kubectlClient, c.dryRun)
+	err := patchGrafanaDeployment(c.logger, c.clientSet, targetGrafanaVersion)
 	if err != nil {
 		return fmt.Errorf("patching grafana deployment: %w", err)
 	}
 
-	err = postflight(c.logger, kubectlClient, c.dryRun)
-	if err != nil {
-		return fmt.Errorf("running postflight checks: %w", err)
-	}
+	return nil
+}
 
-	c.logger.Info("Upgrading Grafana done!")
+// Access checks the user's permissions in the cluster the upgrader talks to
+func (c Upgrader) Access() rbac.Checker {
+	return rbac.New(c.clientSet)
+}
+
+// Blocked returns the requests that were refused because they would have changed the cluster in dry-run mode
+func (c Upgrader) Blocked() []string {
+	if client, ok := c.clientSet.(kubeclient.Client); ok {
+		return client.Blocked()
+	}
 
 	return nil
 }
 
 type Opts struct {
-	DryRun  bool
-	Confirm bool
+	DryRun bool
+
+	// Trace records every Kubernetes request. Optional.
+	Trace *trace.Recorder
+
+	// Record saves the Kubernetes requests to a cassette, for replaying in tests. Optional.
+	Record *replay.Recorder
+
+	// Events receives the warning about lost user data. Optional.
+	Events *events.Emitter
+}
+
+// New returns an upgrader for the cluster in KUBECONFIG. In dry-run mode, the client refuses to change the cluster.
+func New(logger logger.Logger, opts Opts) (Upgrader, error) {
+	clientSet, err := kubeclient.NewFromEnv(kubeclient.Opts{
+		DryRun: opts.DryRun,
+		Trace:  opts.Trace,
+		Record: opts.Record,
+	})
+	if err != nil {
+		return Upgrader{}, fmt.Errorf("acquiring kubectl client: %w", err)
+	}
+
+	return NewWithClient(logger, clientSet, opts), nil
 }
 
-func New(logger logger.Logger, opts Opts) Upgrader {
+// NewWithClient returns an upgrader using the given Kubernetes client, for instance a fake one in tests
+func NewWithClient(logger logger.Logger, clientSet kubernetes.Interface, opts Opts) Upgrader {
 	return Upgrader{
-		logger:  logger,
-		dryRun:  opts.DryRun,
-		confirm: opts.Confirm,
+		logger:    logger,
+		clientSet: clientSet,
+		dryRun:    opts.DryRun,
+		events:    opts.Events,
 	}
 }
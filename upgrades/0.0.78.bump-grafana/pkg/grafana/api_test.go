@@ -0,0 +1,107 @@
+package grafana
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/rbac"
+)
+
+func TestUpgrade(t *testing.T) {
+	testCases := []struct {
+		name          string
+		fixtures      []string
+		deny          []rbac.Permission
+		expectStatus  engine.Status
+		expectImage   string
+		expectFailure bool
+	}{
+		{
+			name:         "Should patch Grafana 7.3.5 to 7.5.12",
+			fixtures:     []string{"testdata/grafana-7.3.5.yaml"},
+			expectStatus: engine.StatusApplied,
+			expectImage:  "grafana/grafana:7.5.12",
+		},
+		{
+			name:         "Should do nothing when Grafana is already upgraded",
+			fixtures:     []string{"testdata/grafana-7.5.12.yaml"},
+			expectStatus: engine.StatusSkipped,
+			expectImage:  "grafana/grafana:7.5.12",
+		},
+		{
+			name:         "Should do nothing when Grafana is not installed",
+			fixtures:     []string{"testdata/other-deployment.yaml"},
+			expectStatus: engine.StatusSkipped,
+		},
+		{
+			name:          "Should fail on an unexpected Grafana version",
+			fixtures:      []string{"testdata/grafana-7.0.0.yaml"},
+			expectStatus:  engine.StatusPending,
+			expectImage:   "grafana/grafana:7.0.0",
+			expectFailure: true,
+		},
+		{
+			name:     "Should change nothing when the user isn't allowed to patch Grafana",
+			fixtures: []string{"testdata/grafana-7.3.5.yaml"},
+			deny: []rbac.Permission{
+				{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: monitoringNamespace},
+			},
+			expectStatus:  engine.StatusPending,
+			expectImage:   "grafana/grafana:7.3.5",
+			expectFailure: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := fakecluster.New(t, tc.fixtures...)
+			cluster.Deny(tc.deny...)
+
+			log := logger.New(logger.Error)
+
+			upgrader := NewWithClient(log, cluster.Clientset, Opts{})
+			e := engine.New(log, engine.Opts{Confirm: true, AcceptDataLoss: true, Access: upgrader.Access()})
+
+			summary, err := e.Run(upgrader.Upgrade())
+			if tc.expectFailure != (err != nil) {
+				t.Fatalf("expected failure to be %t, got error: %v", tc.expectFailure, err)
+			}
+
+			if summary.Steps[0].Status != tc.expectStatus {
+				t.Errorf("expected step status %s, got %s", tc.expectStatus, summary.Steps[0].Status)
+			}
+
+			if tc.expectImage != "" {
+				cluster.AssertContainerImage(monitoringNamespace, grafanaDeploymentName, grafanaContainerName, tc.expectImage)
+			}
+		})
+	}
+}
+
+func TestUpgradeRollsBackWhenVerifyFails(t *testing.T) {
+	cluster := fakecluster.New(t, "testdata/grafana-7.3.5.yaml")
+	log := logger.New(logger.Error)
+
+	upgrade := NewWithClient(log, cluster.Clientset, Opts{}).Upgrade()
+
+	errVerify := errors.New("grafana is not healthy")
+	upgrade.Steps[0].Verify = func() error {
+		return errVerify
+	}
+
+	summary, err := engine.New(log, engine.Opts{Confirm: true, AcceptDataLoss: true}).Run(upgrade)
+	if !errors.Is(err, errVerify) {
+		t.Fatalf("expected verify error, got %v", err)
+	}
+
+	if summary.Steps[0].Status != engine.StatusRolledBack {
+		t.Errorf("expected step status %s, got %s", engine.StatusRolledBack, summary.Steps[0].Status)
+	}
+
+	cluster.AssertContainerImage(monitoringNamespace, grafanaDeploymentName, grafanaContainerName, "grafana/grafana:7.3.5")
+}
@@ -1,6 +0,0 @@
-package grafana
-
-import "errors"
-
-// ErrNothingToDo indicates apathy
-var ErrNothingToDo = errors.New("nothing to do")
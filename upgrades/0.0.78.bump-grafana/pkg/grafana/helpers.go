@@ -2,11 +2,9 @@ package grafana
 
 import (
 	"fmt"
-	"github.com/AlecAivazis/survey/v2"
-	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/commonerrors"
 
-	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/logger"
-	"k8s.io/client-go/kubernetes"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/events"
 
 	"github.com/Masterminds/semver"
 )
@@ -19,8 +17,8 @@ func validateVersion(expected *semver.Version, actual *semver.Version) error {
 	return nil
 }
 
-func (c Upgrader) preflight(clientSet *kubernetes.Clientset) error {
-	hasGrafana, err := hasGrafanaInstalled(clientSet)
+func (c Upgrader) preflight() error {
+	hasGrafana, err := hasGrafanaInstalled(c.clientSet)
 	if err != nil {
 		return fmt.Errorf("checking Grafana existence: %w", err)
 	}
@@ -28,10 +26,10 @@ func (c Upgrader) preflight(clientSet *kubernetes.Clientset) error {
 	if !hasGrafana {
 		c.logger.Info("Grafana is not installed, ignoring upgrade")
 
-		return ErrNothingToDo
+		return commonerrors.ErrNothingToDo
 	}
 
-	currentGrafanaVersion, err := getCurrentGrafanaVersion(clientSet)
+	currentGrafanaVersion, err := getCurrentGrafanaVersion(c.clientSet)
 	if err != nil {
 		return fmt.Errorf("getting current Grafana version: %w", err)
 	}
@@ -41,7 +39,7 @@ func (c Upgrader) preflight(clientSet *kubernetes.Clientset) error {
 		if currentGrafanaVersion.GreaterThan(targetGrafanaVersion) || currentGrafanaVersion.Equal(targetGrafanaVersion) {
 			c.logger.Info(fmt.Sprintf("Current version is %s, ignoring upgrade", currentGrafanaVersion.String()))
 
-			return ErrNothingToDo
+			return commonerrors.ErrNothingToDo
 		}
 
 		return fmt.Errorf("unexpected Grafana version installed: %w", err)
@@ -49,82 +47,76 @@ func (c Upgrader) preflight(clientSet *kubernetes.Clientset) error {
 
 	c.showWarningMessage()
 
-	if !c.dryRun && !c.confirm {
-		yes, err := c.askUser("Do you want to continue?")
-		if err != nil {
-			return fmt.Errorf("prompting user: %w", err)
-		}
+	return nil
+}
+
+// preconditions returns the Grafana image a saved plan was made for. The image is empty if Grafana is not installed.
+func (c Upgrader) preconditions() (map[string]string, error) {
+	hasGrafana, err := hasGrafanaInstalled(c.clientSet)
+	if err != nil {
+		return nil, fmt.Errorf("checking Grafana existence: %w", err)
+	}
+
+	image := ""
 
-		if !yes {
-			return commonerrors.ErrUserAborted
+	if hasGrafana {
+		image, err = getGrafanaImage(c.clientSet)
+		if err != nil {
+			return nil, fmt.Errorf("getting Grafana image: %w", err)
 		}
 	}
 
-	return nil
+	return map[string]string{
+		"grafanaImage": image,
+	}, nil
 }
 
 func (c Upgrader) showWarningMessage() {
+	c.events.Emit(events.Event{
+		Type:    events.TypeWarning,
+		Upgrade: upgradeName,
+		Message: Info().Warning,
+	})
+
 	if c.dryRun {
 		c.logger.Infof(`
 
 
 ***** WARNING *****
-During this upgrade, Grafana needs to restart, and all user data stored in Grafana including dashboards, WILL be lost ` +
-			`(unless you have added configuration to prevent this)!
+%s
 
-For more details and possible mitigations, see: https://www.okctl.io/new-upgrade-for-grafana-available
+For more details and possible mitigations, see: %s
 
-`)
+`, userDataWarning, mitigationsURL)
 	} else {
 		c.logger.Infof(`
 
 
 
 ***** WARNING *****
-During this upgrade, Grafana needs to restart, and all user data stored in Grafana including dashboards, WILL be lost ` +
-			`(unless you have added configuration to prevent this)! Logs and metrics will not be affected, as these are ` +
-			`stored in Loki and Prometheus, respectively.
+%s Logs and metrics will not be affected, as these are stored in Loki and Prometheus, respectively.
 
 If you have made no adjustments to Grafana after the initial setup of Okctl, you can safely continue with this upgrade.
 
-For more details and possible mitigations, see: https://www.okctl.io/new-upgrade-for-grafana-available
-
-`)
-	}
-}
-
-func (c Upgrader) askUser(question string) (bool, error) {
-	answer := false
-	prompt := &survey.Confirm{
-		Message: question,
-	}
+For more details and possible mitigations, see: %s
 
-	err := survey.AskOne(prompt, &answer)
-	if err != nil {
-		return false, err
+`, userDataWarning, mitigationsURL)
 	}
-
-	return answer, nil
 }
 
-func postflight(log logger.Logger, clientSet *kubernetes.Clientset, dryRun bool) error {
-	log.Info("Verifying new Grafana version")
+func (c Upgrader) postflight() error {
+	c.logger.Info("Verifying new Grafana version")
 
-	newVersion, err := getCurrentGrafanaVersion(clientSet)
+	newVersion, err := getCurrentGrafanaVersion(c.clientSet)
 	if err != nil {
 		return fmt.Errorf("acquiring updated Grafana version: %w", err)
 	}
 
-	log.Debug(fmt.Sprintf("Found new Grafana version %s", newVersion.String()))
-
-	expectedVersion := targetGrafanaVersion
-	if dryRun {
-		expectedVersion = newVersion
-	}
+	c.logger.Debug(fmt.Sprintf("Found new Grafana version %s", newVersion.String()))
 
-	err = validateVersion(expectedVersion, newVersion)
+	err = validateVersion(targetGrafanaVersion, newVersion)
 	if err != nil {
-		log.Debug("Expected version %s, but got %s", expectedVersion.String(), newVersion.String())
+		c.logger.Debugf("Expected version %s, but got %s\n", targetGrafanaVersion.String(), newVersion.String())
 
 		return fmt.Errorf("validating new version: %w", err)
 	}
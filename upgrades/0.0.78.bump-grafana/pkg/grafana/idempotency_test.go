@@ -0,0 +1,21 @@
+package grafana
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/idempotency"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+)
+
+func TestUpgradeIsIdempotent(t *testing.T) {
+	cluster := fakecluster.New(t, "testdata/grafana-7.3.5.yaml", "testdata/other-deployment.yaml")
+
+	idempotency.Run(t, idempotency.Subject{
+		Cluster: cluster,
+		Upgrade: func() engine.Upgrade {
+			return NewWithClient(logger.New(logger.Error), cluster.Clientset, Opts{}).Upgrade()
+		},
+	})
+}
@@ -6,18 +6,17 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
 
 	"github.com/Masterminds/semver"
 
-	"k8s.io/client-go/tools/clientcmd"
-
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
 const (
+	upgradeName           = "Grafana"
 	grafanaRepository     = "grafana/grafana"
 	monitoringNamespace   = "monitoring"
 	grafanaDeploymentName = "kube-prometheus-stack-grafana"
@@ -30,24 +29,26 @@ var (
 	targetGrafanaVersion             = semver.MustParse("7.5.12") //nolint:gochecknoglobals
 )
 
-func acquireKubectlClient(kubeConfigPath string) (*kubernetes.Clientset, error) {
-	cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+func getCurrentGrafanaVersion(clientSet kubernetes.Interface) (*semver.Version, error) {
+	image, err := getGrafanaImage(clientSet)
 	if err != nil {
-		return nil, fmt.Errorf("creating rest config: %w", err)
+		return nil, err
 	}
 
-	client, err := kubernetes.NewForConfig(cfg)
+	parts := strings.Split(image, ":")
+
+	version, err := semver.NewVersion(parts[1])
 	if err != nil {
-		return nil, fmt.Errorf("initializing client: %w", err)
+		return nil, fmt.Errorf("parsing version: %w", err)
 	}
 
-	return client, nil
+	return version, nil
 }
 
-func getCurrentGrafanaVersion(clientSet *kubernetes.Clientset) (*semver.Version, error) {
+func getGrafanaImage(clientSet kubernetes.Interface) (string, error) {
 	grafanaContainerIndex, err := getContainerIndexByName(clientSet, grafanaContainerName)
 	if err != nil {
-		return nil, fmt.Errorf("getting Grafana container index: %w", err)
+		return "", fmt.Errorf("getting Grafana container index: %w", err)
 	}
 
 	result, err := clientSet.AppsV1().Deployments(monitoringNamespace).Get(
@@ -56,43 +57,23 @@ func getCurrentGrafanaVersion(clientSet *kubernetes.Clientset) (*semver.Version,
 		metav1.GetOptions{},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("getting deployment: %w", err)
+		return "", fmt.Errorf("getting deployment: %w", err)
 	}
 
-	parts := strings.Split(result.Spec.Template.Spec.Containers[grafanaContainerIndex].Image, ":")
-
-	version, err := semver.NewVersion(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("parsing version: %w", err)
-	}
-
-	return version, nil
+	return result.Spec.Template.Spec.Containers[grafanaContainerIndex].Image, nil
 }
 
-func patchGrafanaDeployment(log logger.Logger, clientSet *kubernetes.Clientset, dryRun bool) error {
-	dryRunOpts := []string{metav1.DryRunAll}
-	if !dryRun {
-		dryRunOpts = nil
-	}
-
-	log.Info("Identifying relevant container")
-
-	grafanaContainerIndex, err := getContainerIndexByName(clientSet, grafanaContainerName)
-	if err != nil {
-		return fmt.Errorf("acquiring Grafana container index: %w", err)
-	}
-
-	log.Debug(fmt.Sprintf("found relevant container at index %d", grafanaContainerIndex))
+func patchGrafanaDeployment(log logger.Logger, clientSet kubernetes.Interface, version *semver.Version) error {
+	log = log.With("resource", fmt.Sprintf("deployment %s/%s", monitoringNamespace, grafanaDeploymentName))
 
 	log.Info("Generating upgrade patch")
 
-	patch := Patch{
-		Op:    jsonPatchOperationReplace,
-		Path:  fmt.Sprintf("/spec/template/spec/containers/%d/image", grafanaContainerIndex),
-		Value: fmt.Sprintf("%s:%s", grafanaRepository, targetGrafanaVersion.String()),
+	patches, err := buildGrafanaPatch(log, clientSet, version)
+	if err != nil {
+		return fmt.Errorf("building patch: %w", err)
 	}
 
-	raw, err := json.Marshal([]Patch{patch})
+	raw, err := json.Marshal(patches)
 	if err != nil {
 		return fmt.Errorf("marshalling patch: %w", err)
 	}
@@ -104,7 +85,7 @@ func patchGrafanaDeployment(log logger.Logger, clientSet *kubernetes.Clientset,
 		grafanaDeploymentName,
 		types.JSONPatchType,
 		raw,
-		metav1.PatchOptions{DryRun: dryRunOpts},
+		metav1.PatchOptions{},
 	)
 	if err != nil {
 		return fmt.Errorf("patching Grafana deployment: %w", err)
@@ -113,7 +94,27 @@ func patchGrafanaDeployment(log logger.Logger, clientSet *kubernetes.Clientset,
 	return nil
 }
 
-func hasGrafanaInstalled(clientSet *kubernetes.Clientset) (bool, error) {
+// buildGrafanaPatch returns the patch that sets the Grafana image to the given version
+func buildGrafanaPatch(log logger.Logger, clientSet kubernetes.Interface, version *semver.Version) ([]Patch, error) {
+	log.Debug("Identifying relevant container")
+
+	grafanaContainerIndex, err := getContainerIndexByName(clientSet, grafanaContainerName)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring Grafana container index: %w", err)
+	}
+
+	log.Debug(fmt.Sprintf("found relevant container at index %d", grafanaContainerIndex))
+
+	return []Patch{
+		{
+			Op:    jsonPatchOperationReplace,
+			Path:  fmt.Sprintf("/spec/template/spec/containers/%d/image", grafanaContainerIndex),
+			Value: fmt.Sprintf("%s:%s", grafanaRepository, version.String()),
+		},
+	}, nil
+}
+
+func hasGrafanaInstalled(clientSet kubernetes.Interface) (bool, error) {
 	result, err := clientSet.AppsV1().Deployments(monitoringNamespace).List(context.Background(), metav1.ListOptions{
 		TimeoutSeconds: int64Ptr(timeoutSeconds),
 	})
@@ -130,7 +131,7 @@ func hasGrafanaInstalled(clientSet *kubernetes.Clientset) (bool, error) {
 	return false, nil
 }
 
-func getContainerIndexByName(clientSet *kubernetes.Clientset, name string) (int, error) {
+func getContainerIndexByName(clientSet kubernetes.Interface, name string) (int, error) {
 	result, err := clientSet.AppsV1().Deployments(monitoringNamespace).Get(
 		context.Background(),
 		grafanaDeploymentName,
@@ -0,0 +1,34 @@
+package grafana
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/replay"
+)
+
+// The cassette is synthetic: it was written by hand to match the requests the upgrade makes, and has not been recorded
+// from a cluster. To replace it with a recording, run the upgrade against a staging cluster with Grafana 7.3.5 and
+// --dry-run=false --confirm --i-understand-data-loss --record=pkg/grafana/testdata/upgrade-7.3.5.cassette.json.
+// The engine is built like in upgrade.go, so that the access reviews in a recording are replayed too.
+func TestUpgradeReplay(t *testing.T) {
+	clientSet := replay.NewClient(t, "testdata/upgrade-7.3.5.cassette.json")
+	log := logger.New(logger.Error)
+
+	upgrader := NewWithClient(log, clientSet, Opts{})
+	e := engine.New(log, engine.Opts{
+		Confirm:        true,
+		AcceptDataLoss: true,
+		Access:         upgrader.Access(),
+	})
+
+	summary, err := e.Run(upgrader.Upgrade())
+	if err != nil {
+		t.Fatalf("running upgrade: %s", err)
+	}
+
+	if summary.Steps[0].Status != engine.StatusApplied {
+		t.Errorf("expected status %s, got %s", engine.StatusApplied, summary.Steps[0].Status)
+	}
+}
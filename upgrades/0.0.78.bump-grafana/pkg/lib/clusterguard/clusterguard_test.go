@@ -0,0 +1,135 @@
+package clusterguard
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	declared := Cluster{Name: "my-cluster", Region: "eu-west-1", AccountID: "123456789012"}
+
+	matching := Environment{
+		Context:   "someone@my-cluster.eu-west-1.eksctl.io",
+		Cluster:   "my-cluster.eu-west-1.eksctl.io",
+		Server:    "https://ABCDEF0123456789.gr7.eu-west-1.eks.amazonaws.com",
+		AccountID: "123456789012",
+	}
+
+	testCases := []struct {
+		name        string
+		env         func(env Environment) Environment
+		expectDiffs []string
+	}{
+		{
+			name: "Should accept the declared cluster",
+			env:  func(env Environment) Environment { return env },
+		},
+		{
+			name: "Should refuse another cluster in the same account",
+			env: func(env Environment) Environment {
+				env.Context = "someone@other-cluster.eu-west-1.eksctl.io"
+				env.Cluster = "other-cluster.eu-west-1.eksctl.io"
+
+				return env
+			},
+			expectDiffs: []string{"kubeconfig context", "kubeconfig cluster"},
+		},
+		{
+			name: "Should refuse a server in another region",
+			env: func(env Environment) Environment {
+				env.Server = "https://ABCDEF0123456789.gr7.eu-north-1.eks.amazonaws.com"
+
+				return env
+			},
+			expectDiffs: []string{"kubeconfig server"},
+		},
+		{
+			name: "Should refuse a server that isn't on EKS",
+			env: func(env Environment) Environment {
+				env.Server = "https://127.0.0.1:6443"
+
+				return env
+			},
+			expectDiffs: []string{"kubeconfig server"},
+		},
+		{
+			name: "Should refuse credentials for another account",
+			env: func(env Environment) Environment {
+				env.AccountID = "210987654321"
+
+				return env
+			},
+			expectDiffs: []string{"AWS account: expected 123456789012, got 210987654321"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := Check(declared, tc.env(matching))
+
+			if len(tc.expectDiffs) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrWrongCluster) {
+				t.Fatalf("expected error %v, got %v", ErrWrongCluster, err)
+			}
+
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines)-1 != len(tc.expectDiffs) {
+				t.Errorf("expected %d differences, got:\n%s", len(tc.expectDiffs), err)
+			}
+
+			for _, diff := range tc.expectDiffs {
+				if !strings.Contains(err.Error(), diff) {
+					t.Errorf("expected the error to contain %s, got:\n%s", diff, err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+
+	err := ioutil.WriteFile(path, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: my-cluster.eu-west-1.eksctl.io
+  cluster:
+    server: https://ABCDEF0123456789.gr7.eu-west-1.eks.amazonaws.com
+contexts:
+- name: someone@my-cluster.eu-west-1.eksctl.io
+  context:
+    cluster: my-cluster.eu-west-1.eksctl.io
+    user: someone@my-cluster.eu-west-1.eksctl.io
+current-context: someone@my-cluster.eu-west-1.eksctl.io
+`), 0o600)
+	if err != nil {
+		t.Fatalf("writing kubeconfig: %s", err)
+	}
+
+	env, err := ReadKubeconfig(path)
+	if err != nil {
+		t.Fatalf("reading kubeconfig: %s", err)
+	}
+
+	expect := Environment{
+		Context: "someone@my-cluster.eu-west-1.eksctl.io",
+		Cluster: "my-cluster.eu-west-1.eksctl.io",
+		Server:  "https://ABCDEF0123456789.gr7.eu-west-1.eks.amazonaws.com",
+	}
+
+	if env != expect {
+		t.Errorf("expected %+v, got %+v", expect, env)
+	}
+}
@@ -0,0 +1,11 @@
+package commonerrors
+
+import "errors"
+
+var ErrUserAborted = errors.New("aborted by user")
+
+// ErrNothingToDo is returned by preflight checks when the upgrade, or a step of it, does not apply to the cluster
+var ErrNothingToDo = errors.New("nothing to do")
+
+// ErrPlanOutdated is returned when applying a saved plan to a cluster that has changed since the plan was made
+var ErrPlanOutdated = errors.New("the cluster has changed since the plan was made")
@@ -0,0 +1,213 @@
+// Package contract tests that an upgrade binary follows the rules in the README. Call Run from a test in the
+// upgrade's main package.
+package contract
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/exitcode"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/prompt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterName is the name of the fake cluster
+const clusterName = "test-cluster"
+
+// Env contains the fakes an upgrade's root command must use instead of the real environment
+type Env struct {
+	// Prompter answers the upgrade's questions
+	Prompter prompt.Prompter
+
+	// ClientSet is a fake cluster seeded with Subject.Fixtures
+	ClientSet kubernetes.Interface
+
+	// ClusterName is the name of the fake cluster, which the user types to confirm destructive steps
+	ClusterName string
+
+	// Record is okctl's record of whether the upgrade has been applied, which starts out as not applied
+	Record engine.Record
+}
+
+// fakeRecord keeps whether the upgrade has been applied in memory
+type fakeRecord struct {
+	applied bool
+}
+
+func (f *fakeRecord) IsApplied() (bool, error) {
+	return f.applied, nil
+}
+
+func (f *fakeRecord) MarkApplied() error {
+	f.applied = true
+
+	return nil
+}
+
+// Subject is the upgrade binary under test
+type Subject struct {
+	// NewCommand returns a new root command, as built by buildRootCommand, that uses the given environment
+	NewCommand func(env Env) *cobra.Command
+
+	// ExitCode returns the exit code main() exits with when the root command returns the given error
+	ExitCode func(err error) int
+
+	// Fixtures are YAML files the fake cluster is seeded with. They should contain a cluster the upgrade applies to.
+	Fixtures []string
+
+	// Mutations returns the changes the command NewCommand returned last made outside the fake cluster, such as calls
+	// to fake services that change something. Optional.
+	Mutations func() []string
+}
+
+// Run verifies that the upgrade has the required flags, aborts with the right exit code when the user answers no,
+// doesn't change the cluster in dry-run mode, and records that it has been applied after a successful run
+func Run(t *testing.T, s Subject) {
+	t.Helper()
+
+	t.Run("Should have the required flags", func(t *testing.T) {
+		Flags(t, s.NewCommand(Env{}))
+	})
+
+	t.Run("Should abort when the user answers no", func(t *testing.T) {
+		cluster := fakecluster.New(t, s.Fixtures...)
+		prompter := prompt.NewTest(t, map[string]interface{}{engine.QuestionContinue.ID: false})
+
+		err := execute(s.NewCommand(Env{
+			Prompter:    prompter,
+			ClientSet:   cluster.Clientset,
+			ClusterName: clusterName,
+			Record:      &fakeRecord{},
+		}), "--dry-run=false")
+
+		if len(prompter.Asked) == 0 {
+			t.Fatalf("expected the user to be asked for confirmation, got error: %v", err)
+		}
+
+		if !errors.Is(err, commonerrors.ErrUserAborted) {
+			t.Errorf("expected error %v, got %v", commonerrors.ErrUserAborted, err)
+		}
+
+		if code := s.ExitCode(err); code != exitcode.Aborted {
+			t.Errorf("expected exit code %d, got %d", exitcode.Aborted, code)
+		}
+
+		assertNoMutations(t, s, cluster)
+	})
+
+	t.Run("Should not change the cluster in dry-run mode", func(t *testing.T) {
+		cluster := fakecluster.New(t, s.Fixtures...)
+		record := &fakeRecord{}
+
+		// No answers, as nothing should be asked in dry-run mode
+		err := execute(s.NewCommand(Env{
+			Prompter:    prompt.NewTest(t, nil),
+			ClientSet:   cluster.Clientset,
+			ClusterName: clusterName,
+			Record:      record,
+		}))
+		if err != nil {
+			t.Fatalf("expected dry-run to succeed, got error: %v", err)
+		}
+
+		assertNoMutations(t, s, cluster)
+
+		if record.applied {
+			t.Errorf("expected the upgrade not to be marked as applied in dry-run mode")
+		}
+	})
+
+	t.Run("Should mark the upgrade as applied after a successful run", func(t *testing.T) {
+		cluster := fakecluster.New(t, s.Fixtures...)
+		record := &fakeRecord{}
+
+		err := execute(s.NewCommand(Env{
+			Prompter:    prompt.NewTest(t, nil),
+			ClientSet:   cluster.Clientset,
+			ClusterName: clusterName,
+			Record:      record,
+		}), "--dry-run=false", "--confirm", "--i-understand-data-loss")
+		if err != nil {
+			t.Fatalf("expected the upgrade to succeed, got error: %v", err)
+		}
+
+		if !record.applied {
+			t.Errorf("expected the upgrade to be marked as applied")
+		}
+	})
+}
+
+// Flags verifies that the command has the flags the README requires, and that dry-run is the default
+func Flags(t *testing.T, cmd *cobra.Command) {
+	t.Helper()
+
+	testCases := []struct {
+		name      string
+		shorthand string
+		defValue  string
+	}{
+		{name: "debug", shorthand: "d", defValue: "false"},
+		{name: "dry-run", shorthand: "n", defValue: "true"},
+		{name: "confirm", shorthand: "c", defValue: "false"},
+	}
+
+	for _, tc := range testCases {
+		flag := lookupFlag(cmd, tc.name)
+		if flag == nil {
+			t.Errorf("expected flag --%s", tc.name)
+			continue
+		}
+
+		if flag.Shorthand != tc.shorthand {
+			t.Errorf("expected flag --%s to have shorthand -%s, got '%s'", tc.name, tc.shorthand, flag.Shorthand)
+		}
+
+		if flag.DefValue != tc.defValue {
+			t.Errorf("expected flag --%s to default to %s, got %s", tc.name, tc.defValue, flag.DefValue)
+		}
+	}
+}
+
+func lookupFlag(cmd *cobra.Command, name string) *pflag.Flag {
+	flag := cmd.PersistentFlags().Lookup(name)
+	if flag != nil {
+		return flag
+	}
+
+	return cmd.Flags().Lookup(name)
+}
+
+// execute runs the command with the given arguments instead of the test binary's arguments
+func execute(cmd *cobra.Command, args ...string) error {
+	cmd.SetArgs(args)
+
+	return cmd.Execute()
+}
+
+func assertNoMutations(t *testing.T, s Subject, cluster fakecluster.Cluster) {
+	t.Helper()
+
+	var calls []string
+
+	for _, action := range cluster.Mutations() {
+		calls = append(calls, fmt.Sprintf("%s %s in namespace '%s'", action.GetVerb(), action.GetResource().Resource,
+			action.GetNamespace()))
+	}
+
+	if s.Mutations != nil {
+		calls = append(calls, s.Mutations()...)
+	}
+
+	if len(calls) == 0 {
+		return
+	}
+
+	t.Errorf("expected no changes to the cluster or other services, got:\n%s", strings.Join(calls, "\n"))
+}
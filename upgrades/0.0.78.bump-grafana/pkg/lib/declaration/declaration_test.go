@@ -0,0 +1,57 @@
+package declaration
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	testCases := []struct {
+		name        string
+		content     string
+		expect      Metadata
+		expectError bool
+	}{
+		{
+			name: "Should read the cluster metadata",
+			content: `apiVersion: okctl.io/v1alpha1
+kind: Cluster
+metadata:
+  name: my-cluster
+  region: eu-west-1
+  accountID: "123456789012"
+integrations:
+  argoCD: true
+`,
+			expect: Metadata{Name: "my-cluster", Region: "eu-west-1", AccountID: "123456789012"},
+		},
+		{
+			name:        "Should fail without a cluster name",
+			content:     "kind: Cluster\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cluster.yaml")
+
+			err := ioutil.WriteFile(path, []byte(tc.content), 0o600)
+			if err != nil {
+				t.Fatalf("writing cluster declaration: %s", err)
+			}
+
+			d, err := Read(path)
+			if tc.expectError != (err != nil) {
+				t.Fatalf("expected error: %t, got %v", tc.expectError, err)
+			}
+
+			if d.Metadata != tc.expect {
+				t.Errorf("expected %+v, got %+v", tc.expect, d.Metadata)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/clusterguard"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubernetesTimeout is how long to wait for the Kubernetes API before giving up
+const kubernetesTimeout = 10 * time.Second
+
+const fixVenv = "Run the upgrade from a shell started with okctl venv, which sets the environment okctl uses."
+
+// Env checks that the environment variable is set
+func Env(name string) Check {
+	return Check{
+		Name: name,
+		Run: func() (string, error) {
+			value, ok := os.LookupEnv(name)
+			if !ok || value == "" {
+				return "", errors.New("not set")
+			}
+
+			return value, nil
+		},
+		Fix: fixVenv,
+	}
+}
+
+// Kubernetes checks that the API server in KUBECONFIG can be reached with the credentials there
+func Kubernetes() Check {
+	return Check{
+		Name: "Kubernetes API",
+		Run: func() (string, error) {
+			// Without a path, client-go would try the in-cluster config instead
+			kubeConfigPath := os.Getenv("KUBECONFIG")
+			if kubeConfigPath == "" {
+				return "", errors.New("KUBECONFIG not set")
+			}
+
+			cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+			if err != nil {
+				return "", fmt.Errorf("reading kubeconfig: %w", err)
+			}
+
+			cfg.Timeout = kubernetesTimeout
+
+			clientSet, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return "", fmt.Errorf("creating client: %w", err)
+			}
+
+			version, err := clientSet.Discovery().ServerVersion()
+			if err != nil {
+				return "", fmt.Errorf("reaching %s: %w", cfg.Host, err)
+			}
+
+			return fmt.Sprintf("Kubernetes %s at %s", version.GitVersion, cfg.Host), nil
+		},
+		Fix: fixVenv + " If it still fails, check that the cluster exists and that the AWS credentials haven't expired.",
+	}
+}
+
+// AWSCredentials checks that the AWS credentials in the environment are valid
+func AWSCredentials() Check {
+	return Check{
+		Name: "AWS credentials",
+		Run: func() (string, error) {
+			account, err := clusterguard.AWSAccount()
+			if err != nil {
+				return "", err
+			}
+
+			return "account " + account, nil
+		},
+		Fix: "Log in again with okctl venv, or export valid AWS credentials.",
+	}
+}
+
+// SameCluster checks that KUBECONFIG and the AWS credentials point at the declared cluster
+func SameCluster(declared func() (clusterguard.Cluster, error)) Check {
+	return Check{
+		Name: "Same cluster",
+		Run: func() (string, error) {
+			cluster, err := declared()
+			if err != nil {
+				return "", err
+			}
+
+			env, err := clusterguard.FromEnv()
+			if err != nil {
+				return "", err
+			}
+
+			err = clusterguard.Check(cluster, env)
+			if err != nil {
+				return "", err
+			}
+
+			return cluster.Name, nil
+		},
+		Fix: "Start okctl venv for the cluster in OKCTL_CLUSTER_DECLARATION, so that KUBECONFIG and the AWS " +
+			"credentials point at it.",
+	}
+}
@@ -0,0 +1,112 @@
+// Package doctor checks every prerequisite for running an upgrade outside okctl upgrade at once, and tells the user
+// how to fix what's missing, instead of the upgrade failing on the first missing prerequisite
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/output"
+)
+
+// ErrFailed is returned when one or more checks failed
+var ErrFailed = errors.New("prerequisites missing")
+
+// Check is a single prerequisite
+type Check struct {
+	// Name is shown in the table, for instance "KUBECONFIG" or "AWS credentials"
+	Name string
+
+	// Run returns an error if the prerequisite is missing. If not, it returns details worth showing, such as the
+	// path that was checked.
+	Run func() (string, error)
+
+	// Fix tells the user how to fix a failure
+	Fix string
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// Run runs every check, also after one has failed
+func Run(checks []Check) []Result {
+	results := make([]Result, len(checks))
+
+	for i, check := range checks {
+		detail, err := check.Run()
+
+		results[i] = Result{Name: check.Name, OK: err == nil, Detail: detail}
+
+		if err != nil {
+			// Some errors list several problems on separate lines, which would break the table
+			results[i].Detail = strings.Join(strings.Fields(err.Error()), " ")
+			results[i].Fix = check.Fix
+		}
+	}
+
+	return results
+}
+
+// Failed returns ErrFailed with the number of failed checks, or nil if all passed
+func Failed(results []Result) error {
+	failed := 0
+
+	for _, r := range results {
+		if !r.OK {
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d checks failed", ErrFailed, failed, len(results))
+}
+
+// Write writes the results to w in the given format. The text format is a table, followed by how to fix each failure.
+func Write(w io.Writer, format output.Format, results []Result) error {
+	if format != output.FormatText {
+		return output.Write(w, format, results)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(tw, "CHECK\tRESULT\tDETAILS")
+
+	for _, r := range results {
+		result := "ok"
+		if !r.OK {
+			result = "FAIL"
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Name, result, r.Detail)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+
+	if Failed(results) == nil {
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(w, "\nTo fix:")
+
+	for _, r := range results {
+		if !r.OK && r.Fix != "" {
+			_, _ = fmt.Fprintf(w, "  %s: %s\n", r.Name, r.Fix)
+		}
+	}
+
+	return nil
+}
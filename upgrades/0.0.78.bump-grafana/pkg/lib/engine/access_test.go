@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/prompt"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/rbac"
+)
+
+type fakeAccess struct {
+	checked []rbac.Permission
+	err     error
+}
+
+func (f *fakeAccess) Check(permissions []rbac.Permission) error {
+	f.checked = append(f.checked, permissions...)
+
+	return f.err
+}
+
+func TestCheckAccess(t *testing.T) {
+	patchDeployments := rbac.Permission{Verb: "patch", Group: "apps", Resource: "deployments", Namespace: "monitoring"}
+	deleteIngresses := rbac.Permission{Verb: "delete", Group: "networking.k8s.io", Resource: "ingresses",
+		Namespace: "argocd"}
+	errMissing := errors.New("missing permissions")
+
+	testCases := []struct {
+		name          string
+		opts          Opts
+		accessErr     error
+		skipSecond    bool
+		expectChecked []rbac.Permission
+		expectApplied bool
+	}{
+		{
+			name:          "Should check the permissions of every pending step before applying",
+			opts:          Opts{Confirm: true},
+			expectChecked: []rbac.Permission{patchDeployments, deleteIngresses},
+			expectApplied: true,
+		},
+		{
+			name:          "Should abort before asking or applying anything when permissions are missing",
+			accessErr:     errMissing,
+			expectChecked: []rbac.Permission{patchDeployments, deleteIngresses},
+		},
+		{
+			name:          "Should check permissions in dry-run mode",
+			opts:          Opts{DryRun: true},
+			accessErr:     errMissing,
+			expectChecked: []rbac.Permission{patchDeployments, deleteIngresses},
+		},
+		{
+			name:          "Should not check the permissions of skipped steps",
+			opts:          Opts{Confirm: true},
+			skipSecond:    true,
+			expectChecked: []rbac.Permission{patchDeployments},
+			expectApplied: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			applied := false
+			apply := func(_ *Undo) error {
+				applied = true
+
+				return nil
+			}
+
+			var preflight func() error
+			if tc.skipSecond {
+				preflight = func() error { return commonerrors.ErrNothingToDo }
+			}
+
+			access := &fakeAccess{err: tc.accessErr}
+
+			tc.opts.Access = access
+			tc.opts.Prompter = prompt.NewTest(t, nil)
+
+			e := New(logger.New(logger.Error), tc.opts)
+
+			_, err := e.Run(Upgrade{Name: "test", Steps: []Step{
+				{Name: "first", Apply: apply, Permissions: []rbac.Permission{patchDeployments}},
+				{Name: "second", Apply: apply, Preflight: preflight, Permissions: []rbac.Permission{deleteIngresses}},
+			}})
+
+			var preflightErr PreflightError
+
+			if tc.accessErr != nil && (!errors.Is(err, tc.accessErr) || !errors.As(err, &preflightErr)) {
+				t.Fatalf("expected a preflight error wrapping %v, got %v", tc.accessErr, err)
+			}
+
+			if tc.accessErr == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(access.checked, tc.expectChecked) {
+				t.Errorf("expected permissions %v to be checked, got %v", tc.expectChecked, access.checked)
+			}
+
+			if applied != tc.expectApplied {
+				t.Errorf("expected applied: %t, got %t", tc.expectApplied, applied)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/commonerrors"
+)
+
+// Risk describes how much harm an action can do if something goes wrong
+type Risk string
+
+const (
+	// RiskLow means the action only reads state, or is trivially reverted
+	RiskLow Risk = "low"
+	// RiskMedium means the action changes the cluster, but can be rolled back
+	RiskMedium Risk = "medium"
+	// RiskHigh means the action deletes data or causes downtime
+	RiskHigh Risk = "high"
+)
+
+// Action is a single change a step intends to make
+type Action struct {
+	// Description says what the action does, for instance "delete secret"
+	Description string `json:"description"`
+	// Resource identifies what is changed, for instance "deployment monitoring/grafana"
+	Resource string `json:"resource"`
+	// Patch is the patch that will be applied to the resource, if any
+	Patch interface{} `json:"patch,omitempty"`
+	Risk  Risk        `json:"risk"`
+}
+
+// PlannedStep is a step and the actions it would perform
+type PlannedStep struct {
+	Name        string   `json:"name"`
+	Status      Status   `json:"status"`
+	Destructive bool     `json:"destructive,omitempty"`
+	Actions     []Action `json:"actions,omitempty"`
+}
+
+// Plan describes everything an upgrade would do, without doing it
+type Plan struct {
+	Upgrade       string            `json:"upgrade"`
+	Preconditions map[string]string `json:"preconditions,omitempty"`
+	Steps         []PlannedStep     `json:"steps"`
+
+	// Blocked lists the requests that were refused while planning because they would have changed the cluster. It
+	// should always be empty, see pkg/lib/kubeclient.
+	Blocked []string `json:"blocked,omitempty"`
+}
+
+// Plan runs all preflight checks and returns the actions the pending steps would perform. Nothing is changed.
+func (e Engine) Plan(u Upgrade) (Plan, error) {
+	e.log = e.log.With("upgrade", u.Name)
+
+	preconditions, err := getPreconditions(u)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	summary := newSummary(u.Steps)
+
+	err = e.resume(&summary)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	_, err = e.preflight(u, &summary)
+	if err != nil {
+		return Plan{}, PreflightError{Err: err}
+	}
+
+	err = e.checkAccess(u, summary)
+	if err != nil {
+		return Plan{}, PreflightError{Err: err}
+	}
+
+	plan := Plan{
+		Upgrade:       u.Name,
+		Preconditions: preconditions,
+		Steps:         make([]PlannedStep, len(u.Steps)),
+	}
+
+	for i, step := range u.Steps {
+		plan.Steps[i] = PlannedStep{
+			Name:        step.Name,
+			Status:      summary.Steps[i].Status,
+			Destructive: step.Destructive,
+		}
+
+		if summary.Steps[i].Status != StatusPending || step.Plan == nil {
+			continue
+		}
+
+		actions, err := step.Plan()
+		if err != nil {
+			return Plan{}, fmt.Errorf("planning step %s: %w", step.Name, err)
+		}
+
+		plan.Steps[i].Actions = actions
+	}
+
+	return plan, nil
+}
+
+// Apply runs the upgrade like Run, but without asking for confirmation, as the plan has already been reviewed. It
+// fails with commonerrors.ErrPlanOutdated if the cluster no longer matches the plan. Plans with destructive steps
+// also need Opts.AcceptDataLoss.
+func (e Engine) Apply(u Upgrade, plan Plan) (Summary, error) {
+	if plan.Upgrade != u.Name {
+		return newSummary(u.Steps), fmt.Errorf("plan is for %s, not %s", plan.Upgrade, u.Name)
+	}
+
+	err := checkPreconditions(u, plan)
+	if err != nil {
+		return newSummary(u.Steps), PreflightError{Err: err}
+	}
+
+	e.confirm = true
+
+	return e.run(u, &plan)
+}
+
+func getPreconditions(u Upgrade) (map[string]string, error) {
+	if u.Preconditions == nil {
+		return nil, nil
+	}
+
+	preconditions, err := u.Preconditions()
+	if err != nil {
+		return nil, fmt.Errorf("getting preconditions: %w", err)
+	}
+
+	return preconditions, nil
+}
+
+func checkPreconditions(u Upgrade, plan Plan) error {
+	current, err := getPreconditions(u)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]string, 0)
+
+	for key, planned := range plan.Preconditions {
+		if current[key] != planned {
+			changes = append(changes, fmt.Sprintf("%s is '%s', but was '%s' when planning", key, current[key], planned))
+		}
+	}
+
+	if len(changes) > 0 {
+		sort.Strings(changes)
+
+		return fmt.Errorf("%w: %s", commonerrors.ErrPlanOutdated, strings.Join(changes, ", "))
+	}
+
+	return nil
+}
+
+// checkPlannedSteps verifies that the preflight checks agree with the plan on which steps to apply
+func checkPlannedSteps(plan Plan, summary Summary) error {
+	if len(plan.Steps) != len(summary.Steps) {
+		return fmt.Errorf("%w: plan has %d steps, but the upgrade has %d",
+			commonerrors.ErrPlanOutdated, len(plan.Steps), len(summary.Steps))
+	}
+
+	for i, planned := range plan.Steps {
+		current := summary.Steps[i]
+
+		if planned.Name != current.Name {
+			return fmt.Errorf("%w: expected step %s, but the upgrade has step %s",
+				commonerrors.ErrPlanOutdated, planned.Name, current.Name)
+		}
+
+		if planned.Status != current.Status {
+			return fmt.Errorf("%w: step %s was planned as %s, but is now %s",
+				commonerrors.ErrPlanOutdated, planned.Name, planned.Status, current.Status)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+)
+
+// fakeCheckpoints keeps the completed steps in memory
+type fakeCheckpoints struct {
+	completed []string
+}
+
+func (f *fakeCheckpoints) Load() ([]string, error) {
+	return f.completed, nil
+}
+
+func (f *fakeCheckpoints) Save(completedSteps []string) error {
+	f.completed = completedSteps
+
+	return nil
+}
+
+func TestResume(t *testing.T) {
+	boom := errors.New("boom")
+
+	testCases := []struct {
+		name             string
+		completed        []string
+		expectCalls      []string
+		expectStatus     []Status
+		expectRolledBack bool
+	}{
+		{
+			name:             "Should report a full rollback when every step was applied by this run",
+			completed:        []string{},
+			expectCalls:      []string{"apply first", "apply second", "roll back first"},
+			expectStatus:     []Status{StatusRolledBack, StatusFailed},
+			expectRolledBack: true,
+		},
+		{
+			name:         "Should not report a rollback when a step was applied by a previous run",
+			completed:    []string{"first"},
+			expectCalls:  []string{"apply second"},
+			expectStatus: []Status{StatusAppliedPreviously, StatusFailed},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			var calls []string
+
+			checkpoints := &fakeCheckpoints{completed: tc.completed}
+
+			e := New(logger.New(logger.Error), Opts{Confirm: true, Checkpoints: checkpoints})
+
+			summary, err := e.Run(Upgrade{Name: "test", Steps: []Step{
+				{
+					Name: "first",
+					Apply: func(_ *Undo) error {
+						calls = append(calls, "apply first")
+
+						return nil
+					},
+					Rollback: func() error {
+						calls = append(calls, "roll back first")
+
+						return nil
+					},
+				},
+				{
+					Name: "second",
+					Apply: func(_ *Undo) error {
+						calls = append(calls, "apply second")
+
+						return boom
+					},
+				},
+			}})
+
+			var applyErr ApplyError
+			if !errors.Is(err, boom) || !errors.As(err, &applyErr) {
+				t.Fatalf("expected an ApplyError wrapping %v, got %v", boom, err)
+			}
+
+			if applyErr.RolledBack != tc.expectRolledBack {
+				t.Errorf("expected rolled back: %t, got %t", tc.expectRolledBack, applyErr.RolledBack)
+			}
+
+			if !reflect.DeepEqual(calls, tc.expectCalls) {
+				t.Errorf("expected calls %v, got %v", tc.expectCalls, calls)
+			}
+
+			for i, step := range summary.Steps {
+				if step.Status != tc.expectStatus[i] {
+					t.Errorf("expected step %s to be %s, got %s", step.Name, tc.expectStatus[i], step.Status)
+				}
+			}
+
+			// The checkpoint keeps the steps that are still applied, so that a later run resumes after them
+			if !reflect.DeepEqual(checkpoints.completed, tc.completed) {
+				t.Errorf("expected checkpoint %v, got %v", tc.completed, checkpoints.completed)
+			}
+		})
+	}
+}
+
+func TestRunAgainAfterRollback(t *testing.T) {
+	var calls []string
+
+	failLast := true
+	checkpoints := &fakeCheckpoints{}
+
+	record := func(call string) func(_ *Undo) error {
+		return func(_ *Undo) error {
+			calls = append(calls, call)
+
+			return nil
+		}
+	}
+
+	upgrade := Upgrade{
+		Name: "test",
+		Preflight: func() error {
+			calls = append(calls, "preflight")
+
+			return nil
+		},
+		Steps: []Step{
+			{
+				Name:  "first",
+				Apply: record("apply first"),
+				Rollback: func() error {
+					calls = append(calls, "roll back first")
+
+					return nil
+				},
+			},
+			// Has nothing to undo, like waiting for something to be deleted
+			{Name: "second", Apply: record("apply second")},
+			{
+				Name: "third",
+				Apply: func(_ *Undo) error {
+					calls = append(calls, "apply third")
+
+					if failLast {
+						return errors.New("boom")
+					}
+
+					return nil
+				},
+			},
+		},
+	}
+
+	e := New(logger.New(logger.Error), Opts{Confirm: true, Checkpoints: checkpoints})
+
+	summary, err := e.Run(upgrade)
+
+	var applyErr ApplyError
+	if !errors.As(err, &applyErr) || !applyErr.RolledBack {
+		t.Fatalf("expected a rolled back ApplyError, got %v", err)
+	}
+
+	expectStatus := []Status{StatusRolledBack, StatusRolledBack, StatusFailed}
+	for i, step := range summary.Steps {
+		if step.Status != expectStatus[i] {
+			t.Errorf("expected step %s to be %s, got %s", step.Name, expectStatus[i], step.Status)
+		}
+	}
+
+	if len(checkpoints.completed) != 0 {
+		t.Errorf("expected an empty checkpoint after a rollback, got %v", checkpoints.completed)
+	}
+
+	failLast = false
+
+	_, err = e.Run(upgrade)
+	if err != nil {
+		t.Fatalf("expected the second run to succeed, got %v", err)
+	}
+
+	expectCalls := []string{
+		"preflight", "apply first", "apply second", "apply third", "roll back first",
+		"preflight", "apply first", "apply second", "apply third",
+	}
+	if !reflect.DeepEqual(calls, expectCalls) {
+		t.Errorf("expected calls %v, got %v", expectCalls, calls)
+	}
+}
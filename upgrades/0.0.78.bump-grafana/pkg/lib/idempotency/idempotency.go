@@ -0,0 +1,95 @@
+// Package idempotency tests that running an upgrade a second time succeeds without changing anything, as the README
+// requires
+package idempotency
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/logger"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Subject is the upgrade under test
+type Subject struct {
+	// Cluster is the fake cluster the upgrade runs against, seeded with a cluster the upgrade applies to
+	Cluster fakecluster.Cluster
+
+	// Upgrade returns the upgrade to run. It is called once for each run.
+	Upgrade func() engine.Upgrade
+
+	// Snapshot returns the state of anything else the upgrade changes, such as fake okctl services. Optional.
+	Snapshot func() interface{}
+}
+
+// Run applies the upgrade twice, and fails the test if either run fails or the second run changes anything
+func Run(t *testing.T, s Subject) {
+	t.Helper()
+
+	e := engine.New(logger.New(logger.Error), engine.Opts{Confirm: true, AcceptDataLoss: true})
+
+	_, err := e.Run(s.Upgrade())
+	if err != nil {
+		t.Fatalf("first run: %s", err)
+	}
+
+	objects := s.Cluster.Snapshot()
+	mutations := len(s.Cluster.Mutations())
+	other := snapshot(s)
+
+	_, err = e.Run(s.Upgrade())
+	if err != nil {
+		t.Fatalf("second run: %s", err)
+	}
+
+	for _, change := range diff(objects, s.Cluster.Snapshot()) {
+		t.Errorf("second run changed %s", change)
+	}
+
+	for _, action := range s.Cluster.Mutations()[mutations:] {
+		t.Errorf("second run made a %s request for %s in namespace '%s'", action.GetVerb(),
+			action.GetResource().Resource, action.GetNamespace())
+	}
+
+	if after := snapshot(s); !reflect.DeepEqual(other, after) {
+		t.Errorf("second run changed state outside the cluster from\n%+v\nto\n%+v", other, after)
+	}
+}
+
+func snapshot(s Subject) interface{} {
+	if s.Snapshot == nil {
+		return nil
+	}
+
+	return s.Snapshot()
+}
+
+// diff returns the keys of the objects that were added, removed or changed, sorted
+func diff(before, after map[string]runtime.Object) []string {
+	changes := make([]string, 0)
+
+	for key, obj := range before {
+		other, ok := after[key]
+
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s (removed)", key))
+		case !reflect.DeepEqual(obj, other):
+			changes = append(changes, fmt.Sprintf("%s (modified)", key))
+		}
+	}
+
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, fmt.Sprintf("%s (added)", key))
+		}
+	}
+
+	sort.Strings(changes)
+
+	return changes
+}
@@ -0,0 +1,72 @@
+// Package info describes an upgrade, so that okctl and other tooling can show what it does before running it. Each
+// upgrade declares its Info once, and prints it with --info.
+package info
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/output"
+)
+
+// Info is the metadata of an upgrade
+type Info struct {
+	// TargetVersion is the okctl version the upgrade is released for, for instance "0.0.87"
+	TargetVersion string `json:"targetVersion"`
+
+	// ID identifies the upgrade among those for the same version, for instance "argocd"
+	ID string `json:"id"`
+
+	// Components are the parts of the cluster the upgrade changes
+	Components []string `json:"components"`
+
+	// Destructive is true if the upgrade deletes resources or data that can't be fully restored
+	Destructive bool `json:"destructive"`
+
+	// RequiredEnv are the environment variables the upgrade needs, in addition to those from okctl venv
+	RequiredEnv []string `json:"requiredEnv,omitempty"`
+
+	// Warning is shown to the user before the upgrade runs. Optional.
+	Warning string `json:"warning,omitempty"`
+}
+
+// Name returns the name of the upgrade's directory and release, for instance "0.0.87.argocd"
+func (i Info) Name() string {
+	return fmt.Sprintf("%s.%s", i.TargetVersion, i.ID)
+}
+
+// Write writes the info to w in the given format. The text format is meant for humans.
+func Write(w io.Writer, format output.Format, i Info) error {
+	if format != output.FormatText {
+		return output.Write(w, format, i)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	destructive := "no"
+	if i.Destructive {
+		destructive = "yes"
+	}
+
+	_, _ = fmt.Fprintf(tw, "Upgrade:\t%s\n", i.Name())
+	_, _ = fmt.Fprintf(tw, "okctl version:\t%s\n", i.TargetVersion)
+	_, _ = fmt.Fprintf(tw, "Components:\t%s\n", strings.Join(i.Components, ", "))
+	_, _ = fmt.Fprintf(tw, "Destructive:\t%s\n", destructive)
+
+	if len(i.RequiredEnv) > 0 {
+		_, _ = fmt.Fprintf(tw, "Required environment:\t%s\n", strings.Join(i.RequiredEnv, ", "))
+	}
+
+	if i.Warning != "" {
+		_, _ = fmt.Fprintf(tw, "Warning:\t%s\n", i.Warning)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return fmt.Errorf("writing info: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package info
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/output"
+)
+
+func TestWrite(t *testing.T) {
+	i := Info{
+		TargetVersion: "0.0.87",
+		ID:            "argocd",
+		Components:    []string{"argocd"},
+		Destructive:   true,
+		RequiredEnv:   []string{"KUBECONFIG"},
+	}
+
+	testCases := []struct {
+		name   string
+		format output.Format
+		expect string
+	}{
+		{
+			name:   "Should write JSON for okctl",
+			format: output.FormatJSON,
+			expect: `{
+  "targetVersion": "0.0.87",
+  "id": "argocd",
+  "components": [
+    "argocd"
+  ],
+  "destructive": true,
+  "requiredEnv": [
+    "KUBECONFIG"
+  ]
+}
+`,
+		},
+		{
+			name:   "Should write aligned text for humans",
+			format: output.FormatText,
+			expect: `Upgrade:               0.0.87.argocd
+okctl version:         0.0.87
+Components:            argocd
+Destructive:           yes
+Required environment:  KUBECONFIG
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+
+			err := Write(out, tc.format, i)
+			if err != nil {
+				t.Fatalf("writing info: %s", err)
+			}
+
+			if out.String() != tc.expect {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expect, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package kubeclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestGuard(t *testing.T) {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+
+	testCases := []struct {
+		name          string
+		dryRun        bool
+		call          func(c Client) error
+		expectErr     error
+		expectServer  bool
+		expectBlocked []string
+	}{
+		{
+			name:   "Should let reads through in dry-run mode",
+			dryRun: true,
+			call: func(c Client) error {
+				_, err := c.CoreV1().ConfigMaps("default").Get(context.Background(), "test", metav1.GetOptions{})
+
+				return err
+			},
+			expectServer: true,
+		},
+		{
+			name:   "Should refuse writes in dry-run mode",
+			dryRun: true,
+			call: func(c Client) error {
+				_, err := c.CoreV1().ConfigMaps("default").Create(context.Background(), configMap, metav1.CreateOptions{})
+
+				return err
+			},
+			expectErr:     ErrBlocked,
+			expectServer:  false,
+			expectBlocked: []string{"POST /api/v1/namespaces/default/configmaps"},
+		},
+		{
+			name:   "Should refuse deletes in dry-run mode",
+			dryRun: true,
+			call: func(c Client) error {
+				return c.CoreV1().ConfigMaps("default").Delete(context.Background(), "test", metav1.DeleteOptions{})
+			},
+			expectErr:     ErrBlocked,
+			expectServer:  false,
+			expectBlocked: []string{"DELETE /api/v1/namespaces/default/configmaps/test"},
+		},
+		{
+			name:   "Should let server-side dry-run writes through in dry-run mode",
+			dryRun: true,
+			call: func(c Client) error {
+				_, err := c.CoreV1().ConfigMaps("default").Create(context.Background(), configMap, metav1.CreateOptions{
+					DryRun: []string{metav1.DryRunAll},
+				})
+
+				return err
+			},
+			expectServer: true,
+		},
+		{
+			name:   "Should let access reviews through in dry-run mode",
+			dryRun: true,
+			call: func(c Client) error {
+				_, err := c.AuthorizationV1().SelfSubjectAccessReviews().Create(context.Background(),
+					&authorizationv1.SelfSubjectAccessReview{}, metav1.CreateOptions{})
+
+				return err
+			},
+			expectServer: true,
+		},
+		{
+			name:   "Should let writes through when not in dry-run mode",
+			dryRun: false,
+			call: func(c Client) error {
+				_, err := c.CoreV1().ConfigMaps("default").Create(context.Background(), configMap, metav1.CreateOptions{})
+
+				return err
+			},
+			expectServer: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				called = true
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "test"}}`))
+			}))
+			defer server.Close()
+
+			client, err := NewForConfig(&rest.Config{Host: server.URL}, Opts{DryRun: tc.dryRun})
+			if err != nil {
+				t.Fatalf("creating client: %s", err)
+			}
+
+			err = tc.call(client)
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+
+			if called != tc.expectServer {
+				t.Errorf("expected request to reach the server to be %t, got %t", tc.expectServer, called)
+			}
+
+			if !reflect.DeepEqual(client.Blocked(), tc.expectBlocked) {
+				t.Errorf("expected blocked requests %v, got %v", tc.expectBlocked, client.Blocked())
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package okctlstate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/codec/json"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	_, err := OpenFile(path)
+	if err == nil {
+		t.Fatalf("expected an error for a missing local state")
+	}
+
+	// Create the database the way okctl does
+	db, err := storm.Open(path, storm.Codec(json.Codec))
+	if err != nil {
+		t.Fatalf("creating local state: %s", err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatalf("closing local state: %s", err)
+	}
+
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("opening local state: %s", err)
+	}
+
+	record := New(file, ID{ClusterName: "my-cluster"}).For("0.0.1")
+
+	err = record.MarkApplied()
+	if err != nil {
+		t.Fatalf("marking applied: %s", err)
+	}
+
+	applied, err := record.IsApplied()
+	if err != nil {
+		t.Fatalf("reading record: %s", err)
+	}
+
+	if !applied {
+		t.Errorf("expected the upgrade to be recorded as applied")
+	}
+}
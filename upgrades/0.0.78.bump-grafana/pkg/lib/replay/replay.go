@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// T is the part of testing.T the replaying client uses
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(fn func())
+}
+
+// NewClient returns a Kubernetes client that answers requests from the cassette at the given path. Each request is
+// answered by the first unused interaction with the same method, path and body. The test fails on requests that are
+// not in the cassette, and on interactions that are left unused when the test ends.
+func NewClient(t T, path string) kubernetes.Interface {
+	t.Helper()
+
+	cassette, err := Load(path)
+	if err != nil {
+		t.Fatalf("loading cassette: %s", err)
+	}
+
+	transport := &replayingTransport{
+		t:        t,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}
+
+	t.Cleanup(transport.assertAllUsed)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{
+		Host:      "http://replay.invalid",
+		Transport: transport,
+	})
+	if err != nil {
+		t.Fatalf("creating client: %s", err)
+	}
+
+	return clientSet
+}
+
+type replayingTransport struct {
+	t        T
+	lock     sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+func (r *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = req.Body.Close()
+	}
+
+	path := req.URL.RequestURI()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !interaction.Request.matches(req.Method, path, body) {
+			continue
+		}
+
+		r.used[i] = true
+
+		return &http.Response{
+			StatusCode: interaction.Response.Status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(bytes.NewReader(fromBody(interaction.Response.Body))),
+			Request:    req,
+		}, nil
+	}
+
+	r.t.Errorf("unexpected request, not in cassette: %s %s %s", req.Method, path, string(body))
+
+	return nil, fmt.Errorf("replaying %s %s: request not in cassette", req.Method, path)
+}
+
+func (r *replayingTransport) assertAllUsed() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			r.t.Errorf("expected request was not made: %s %s", interaction.Request.Method, interaction.Request.Path)
+		}
+	}
+}
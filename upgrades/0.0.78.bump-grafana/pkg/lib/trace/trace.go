@@ -0,0 +1,122 @@
+// Package trace records the calls an upgrade makes to Kubernetes and okctl, so that a failed upgrade can be debugged
+// from a single file
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/redact"
+)
+
+const (
+	// KindKubernetes is a request to the Kubernetes API
+	KindKubernetes = "kubernetes"
+	// KindOkctl is a call to an okctl service
+	KindOkctl = "okctl"
+)
+
+// Entry is a single recorded call
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Method    string    `json:"method"`
+	Target    string    `json:"target"`
+	Status    int       `json:"status,omitempty"`
+	LatencyMs int64     `json:"latencyMs"`
+	Request   string    `json:"request,omitempty"`
+	Response  string    `json:"response,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Recorder writes entries to a file, one JSON object per line. A nil recorder records nothing, so callers don't need
+// to check whether tracing is enabled.
+type Recorder struct {
+	lock   sync.Mutex
+	out    io.Writer
+	closer io.Closer
+}
+
+// New returns a recorder that writes to the given writer
+func New(out io.Writer) *Recorder {
+	return &Recorder{out: out}
+}
+
+// NewFile returns a recorder that writes to the file at the given path. It returns nil if the path is empty.
+func NewFile(path string) (*Recorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace file: %w", err)
+	}
+
+	return &Recorder{out: f, closer: f}, nil
+}
+
+// Record writes the entry, with registered sensitive values redacted. Errors are ignored, as a broken trace must not
+// stop the upgrade.
+func (r *Recorder) Record(entry Entry) {
+	if r == nil {
+		return
+	}
+
+	entry.Target = redact.String(entry.Target)
+	entry.Request = redact.String(entry.Request)
+	entry.Response = redact.String(entry.Response)
+	entry.Error = redact.String(entry.Error)
+
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, _ = r.out.Write(append(raw, '\n'))
+}
+
+// Call runs fn and records it as a call to the given okctl service method. Only the target is recorded, not the
+// arguments, as they may contain secrets.
+func (r *Recorder) Call(method, target string, fn func() error) error {
+	if r == nil {
+		return fn()
+	}
+
+	start := time.Now()
+
+	err := fn()
+
+	entry := Entry{
+		Time:      start,
+		Kind:      KindOkctl,
+		Method:    method,
+		Target:    target,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.Record(entry)
+
+	return err
+}
+
+// Close closes the trace file, if any
+func (r *Recorder) Close() error {
+	if r == nil || r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
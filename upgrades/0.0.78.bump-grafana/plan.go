@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/grafana"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/trace"
+	"github.com/spf13/cobra"
+)
+
+func buildPlanCommand(flags *cmdFlags, deps Dependencies) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Saves what the upgrade would do to a plan file, which can be reviewed and applied later",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			// The plan is written to stdout if no file is given, so it must be machine-readable
+			if out == "" && flags.output == string(output.FormatText) {
+				flags.output = string(output.FormatJSON)
+			}
+
+			context, err := newContext(*flags, deps)
+			if err != nil {
+				return err
+			}
+
+			return plan(context, *flags, out)
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Write the plan as JSON to this file instead of to stdout.")
+
+	return cmd
+}
+
+func buildApplyCommand(flags *cmdFlags, deps Dependencies) *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply <plan file>",
+		Short: "Applies a plan file made by the plan command, if the cluster still matches it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Applying is the point of this command, so only simulate if explicitly asked to
+			flags.dryRun = flags.dryRun && cmd.Flags().Changed("dry-run")
+
+			context, err := newContext(*flags, deps)
+			if err != nil {
+				return err
+			}
+
+			return apply(context, *flags, args[0])
+		},
+	}
+}
+
+func plan(context Context, flags cmdFlags, out string) error {
+	recorder, err := trace.NewFile(flags.trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	_, err = context.checkCluster()
+	if err != nil {
+		return err
+	}
+
+	c, err := newUpgrader(context, grafana.Opts{DryRun: true, Trace: recorder})
+	if err != nil {
+		return err
+	}
+
+	record, err := newRecord(context)
+	if err != nil {
+		return err
+	}
+
+	e := engine.New(context.logger, engine.Opts{Record: record, Access: c.Access()})
+
+	p, err := e.Plan(c.Upgrade())
+	if err != nil {
+		return fmt.Errorf("planning upgrade: %w", err)
+	}
+
+	p.Blocked = c.Blocked()
+
+	if out == "" {
+		format, err := output.ParseFormat(flags.output)
+		if err != nil {
+			return err
+		}
+
+		return output.Write(os.Stdout, format, p)
+	}
+
+	return writePlan(out, p)
+}
+
+func apply(context Context, flags cmdFlags, planPath string) error {
+	p, err := readPlan(planPath)
+	if err != nil {
+		return err
+	}
+
+	recorder, err := trace.NewFile(flags.trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	_, err = context.checkCluster()
+	if err != nil {
+		return err
+	}
+
+	c, err := newUpgrader(context, grafana.Opts{DryRun: flags.dryRun, Trace: recorder, Events: context.events})
+	if err != nil {
+		return err
+	}
+
+	record, err := newRecord(context)
+	if err != nil {
+		return err
+	}
+
+	e := engine.New(context.logger, engine.Opts{
+		DryRun:         flags.dryRun,
+		NoRollback:     flags.noRollback,
+		Record:         record,
+		Access:         c.Access(),
+		AcceptDataLoss: flags.acceptDataLoss,
+		Events:         context.events,
+		ReportSkipped:  flags.reportSkipped,
+	})
+
+	_, err = e.Apply(c.Upgrade(), p)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func writePlan(path string, p engine.Plan) error {
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling plan: %w", err)
+	}
+
+	err = ioutil.WriteFile(path, append(raw, '\n'), 0o600)
+	if err != nil {
+		return fmt.Errorf("writing plan file: %w", err)
+	}
+
+	return nil
+}
+
+func readPlan(path string) (engine.Plan, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return engine.Plan{}, fmt.Errorf("reading plan file: %w", err)
+	}
+
+	var p engine.Plan
+
+	err = json.Unmarshal(raw, &p)
+	if err != nil {
+		return engine.Plan{}, fmt.Errorf("parsing plan file %s: %w", path, err)
+	}
+
+	return p, nil
+}
@@ -1,19 +1,101 @@
 package main
 
-import "github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/grafana"
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/grafana"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.78.bump-grafana/pkg/lib/trace"
+)
 
 func upgrade(context Context, flags cmdFlags) error {
+	format, err := output.ParseFormat(flags.output)
+	if err != nil {
+		return err
+	}
+
+	if format != output.FormatText && !flags.dryRun {
+		return errors.New("--output=json|yaml can only be used together with --dry-run")
+	}
+
+	recorder, err := trace.NewFile(flags.trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	cassette := replay.NewRecorder(flags.record)
+
 	opts := grafana.Opts{
-		DryRun:  flags.dryRun,
-		Confirm: flags.confirm,
+		DryRun: flags.dryRun,
+		Trace:  recorder,
+		Record: cassette,
+		Events: context.events,
 	}
 
-	c := grafana.New(context.logger, opts)
+	// Checked before connecting, so that nothing is read from another cluster either
+	clusterName, err := context.checkCluster()
+	if err != nil {
+		return err
+	}
 
-	err := c.Upgrade()
+	c, err := newUpgrader(context, opts)
 	if err != nil {
 		return err
 	}
 
+	record, err := newRecord(context)
+	if err != nil {
+		return err
+	}
+
+	e := engine.New(context.logger, engine.Opts{
+		DryRun:         flags.dryRun,
+		Confirm:        flags.confirm,
+		Record:         record,
+		NoRollback:     flags.noRollback,
+		Prompter:       context.prompter,
+		Access:         c.Access(),
+		ClusterName:    clusterName,
+		AcceptDataLoss: flags.acceptDataLoss,
+		Events:         context.events,
+		ReportSkipped:  flags.reportSkipped,
+	})
+
+	if format != output.FormatText {
+		plan, err := e.Plan(c.Upgrade())
+		if err != nil {
+			return fmt.Errorf("planning upgrade: %w", err)
+		}
+
+		plan.Blocked = c.Blocked()
+
+		return output.Write(os.Stdout, format, plan)
+	}
+
+	_, err = e.Run(c.Upgrade())
+
+	for _, request := range c.Blocked() {
+		context.logger.Infof("Blocked request that would have changed the cluster: %s\n", request)
+	}
+
+	// Save the recording also if the upgrade failed, as failures are worth replaying too
+	saveErr := cassette.Save()
+
+	if err != nil {
+		return err
+	}
+
+	if saveErr != nil {
+		return fmt.Errorf("saving recording: %w", saveErr)
+	}
+
 	return nil
 }
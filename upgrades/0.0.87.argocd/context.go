@@ -1,15 +1,124 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"os"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
 	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/events"
 	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/prompt"
+	"k8s.io/client-go/kubernetes"
 )
 
 type Context struct {
-	log logger.Logger
+	log      logger.Logger
+	events   *events.Emitter
+	prompter prompt.Prompter
+	deps     Dependencies
+}
+
+// Dependencies are the parts of the environment the upgrade talks to. Tests replace them with fakes, see
+// main_test.go.
+type Dependencies struct {
+	// Prompter asks the user questions. Defaults to a prompt in the terminal, or the answers in --answers.
+	Prompter prompt.Prompter
+
+	// ClientSet is the cluster to upgrade. Defaults to the cluster in KUBECONFIG. Only used together with OkctlTools.
+	ClientSet kubernetes.Interface
+
+	// OkctlTools are the okctl services and state the upgrade uses. Defaults to the ones for the cluster declaration.
+	OkctlTools *argocdPkg.OkctlTools
+
+	// ClusterName is the name the user must type to run destructive steps. Defaults to the name in the cluster
+	// declaration, after checking that KUBECONFIG and the AWS credentials point at that cluster. The check is skipped
+	// when the name is set.
+	ClusterName string
+
+	// Record is okctl's record of whether the upgrade has been applied. Defaults to the record in the okctl state the
+	// upgrade uses.
+	Record engine.Record
 }
 
-func newContext(flags cmdflags.Flags) Context {
+func newContext(flags cmdflags.Flags, deps Dependencies) (Context, error) {
+	log, err := newLogger(flags)
+	if err != nil {
+		return Context{}, err
+	}
+
+	if flags.Events == "jsonl" && flags.Output != string(output.FormatText) {
+		return Context{}, errors.New("--events=jsonl and --output=json|yaml can't be combined, as both write to stdout")
+	}
+
+	emitter, err := events.Open(flags.Events)
+	if err != nil {
+		return Context{}, err
+	}
+
+	prompter, err := newPrompter(flags, deps)
+	if err != nil {
+		return Context{}, err
+	}
+
+	return Context{
+		log:      log,
+		events:   emitter,
+		prompter: prompter,
+		deps:     deps,
+	}, nil
+}
+
+// newArgoCD returns the upgrade, using the okctl tools from the dependencies if set, and the name of the cluster. It
+// refuses to run if KUBECONFIG or the AWS credentials point at another cluster than the cluster declaration, before
+// connecting to the cluster or opening the state.
+func newArgoCD(context Context, opts argocdPkg.Opts) (argocdPkg.ArgoCD, string, error) {
+	clusterName := context.deps.ClusterName
+
+	if clusterName == "" {
+		var err error
+
+		clusterName, err = argocdPkg.CheckCluster()
+		if err != nil {
+			return argocdPkg.ArgoCD{}, "", err
+		}
+	}
+
+	if context.deps.OkctlTools != nil {
+		return argocdPkg.NewWithTools(context.log, context.deps.ClientSet, *context.deps.OkctlTools), clusterName, nil
+	}
+
+	argocd, err := argocdPkg.New(context.log, opts)
+	if err != nil {
+		return argocdPkg.ArgoCD{}, "", fmt.Errorf("creating argocd: %w", err)
+	}
+
+	return argocd, clusterName, nil
+}
+
+// newRecord returns the record from the dependencies, or else the upgrade's record in okctl's state
+func newRecord(context Context, argocd argocdPkg.ArgoCD) engine.Record {
+	if context.deps.Record != nil {
+		return context.deps.Record
+	}
+
+	return argocd.Record()
+}
+
+// newPrompter returns the answers in --answers if set, or else the prompter from the dependencies. The prompter is
+// nil to prompt in the terminal.
+func newPrompter(flags cmdflags.Flags, deps Dependencies) (prompt.Prompter, error) {
+	if flags.Answers == "" {
+		return deps.Prompter, nil
+	}
+
+	return prompt.LoadAnswers(flags.Answers)
+}
+
+func newLogger(flags cmdflags.Flags) (logger.Logger, error) {
 	var level logger.Level
 	if flags.Debug {
 		level = logger.Debug
@@ -17,7 +126,31 @@ func newContext(flags cmdflags.Flags) Context {
 		level = logger.Info
 	}
 
-	return Context{
-		log: logger.New(level),
+	format, err := logger.ParseFormat(flags.LogFormat)
+	if err != nil {
+		return logger.Logger{}, err
 	}
+
+	opts := logger.Opts{
+		Level:  level,
+		Format: format,
+		Out:    os.Stdout,
+	}
+
+	// Keep stdout for machine-readable output
+	if flags.Output != string(output.FormatText) || flags.Events == "jsonl" {
+		opts.Out = os.Stderr
+	}
+
+	if flags.LogFile != "" {
+		// Left open until the process exits. Every message is written straight to the file, so nothing is lost.
+		file, err := os.OpenFile(flags.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return logger.Logger{}, fmt.Errorf("opening log file: %w", err)
+		}
+
+		opts.File = file
+	}
+
+	return logger.NewWithOpts(opts), nil
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/doctor"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/output"
+	"github.com/spf13/cobra"
+)
+
+func buildDoctorCommand(flags *cmdflags.Flags, manageState *bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Checks every prerequisite for running the upgrade, and shows how to fix what's missing",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			format, err := output.ParseFormat(flags.Output)
+			if err != nil {
+				return err
+			}
+
+			var checks []doctor.Check
+
+			for _, name := range argocdPkg.Info().RequiredEnv {
+				checks = append(checks, doctor.Env(name))
+			}
+
+			results := doctor.Run(append(checks, argocdPkg.DoctorChecks(*manageState)...))
+
+			err = doctor.Write(os.Stdout, format, results)
+			if err != nil {
+				return err
+			}
+
+			return doctor.Failed(results)
+		},
+	}
+}
@@ -3,11 +3,16 @@ module github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd
 go 1.16
 
 require (
+	github.com/AlecAivazis/survey/v2 v2.3.2
 	github.com/Masterminds/semver v1.5.0
+	github.com/asdine/storm/v3 v3.2.1
+	github.com/aws/aws-sdk-go v1.42.32
 	github.com/miekg/dns v1.1.45
 	github.com/mishudark/errors v0.0.0-20210318113247-bd4e9ef2fc74
 	github.com/oslokommune/okctl v0.0.87
 	github.com/spf13/cobra v1.3.0
+	github.com/spf13/pflag v1.0.5
+	helm.sh/helm/v3 v3.7.2
 	k8s.io/api v0.22.4
 	k8s.io/apimachinery v0.22.4
 	k8s.io/client-go v0.22.4
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd/argocdtest"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/contract"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func TestContract(t *testing.T) {
+	var fake *argocdtest.Okctl
+
+	contract.Run(t, contract.Subject{
+		NewCommand: func(env contract.Env) *cobra.Command {
+			fake = argocdtest.New(env.ClientSet, argocdtest.AppVersionBeforeUpgrade)
+			okctlTools := newFakeOkctlTools(fake)
+
+			return buildRootCommand(Dependencies{
+				Prompter:    env.Prompter,
+				ClientSet:   env.ClientSet,
+				OkctlTools:  &okctlTools,
+				ClusterName: env.ClusterName,
+				Record:      env.Record,
+			})
+		},
+		ExitCode: exitcode.For,
+		Fixtures: []string{"pkg/argocd/testdata/argocd-ingress.yaml"},
+		// Every call the fake okctl services record changes something
+		Mutations: func() []string {
+			return fake.Calls
+		},
+	})
+}
+
+// newFakeOkctlTools returns okctl tools using the given fake services
+func newFakeOkctlTools(fake *argocdtest.Okctl) argocdPkg.OkctlTools {
+	return argocdPkg.NewOkctlTools(argocdtest.Declaration(), argocdPkg.State{
+		Helm:            argocdtest.HelmState{Okctl: fake},
+		Manifest:        argocdtest.ManifestState{},
+		Parameter:       argocdtest.ParameterState{},
+		Domain:          argocdtest.DomainState{},
+		IdentityManager: argocdtest.IdentityManagerState{},
+	}, argocdPkg.Services{
+		Helm:      argocdtest.HelmService{Okctl: fake},
+		Manifest:  argocdtest.ManifestService{Okctl: fake},
+		Parameter: argocdtest.ParameterService{Okctl: fake},
+		Github:    argocdtest.GithubService{Okctl: fake},
+		ArgoCD:    argocdtest.ArgoCDService{Okctl: fake},
+	}, argocdtest.SecretReader{})
+}
+
+func TestInfo(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getting working directory: %s", err)
+	}
+
+	if name := argocdPkg.Info().Name(); name != filepath.Base(wd) {
+		t.Errorf("expected the info to match the directory name %s, got %s", filepath.Base(wd), name)
+	}
+}
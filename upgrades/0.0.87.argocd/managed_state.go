@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/remotestate"
+)
+
+// withManagedState runs fn between downloading and uploading the remote state, if manageState, the --manage-state flag,
+// is set. stop is closed if the process is interrupted, see remotestate.Manager.Run. The flag isn't in cmdflags, as
+// only this upgrade uses okctl's remote state service.
+func withManagedState(
+	context Context,
+	flags cmdflags.Flags,
+	manageState bool,
+	fn func(stop <-chan struct{}) error,
+) error {
+	if !manageState {
+		return fn(nil)
+	}
+
+	remote, localStatePath, err := argocdPkg.NewRemoteState()
+	if err != nil {
+		return fmt.Errorf("getting remote state: %w", err)
+	}
+
+	m := remotestate.New(context.log, remote, localStatePath, remotestate.Opts{
+		ReadOnly: flags.DryRun,
+	})
+
+	return m.Run(fn)
+}
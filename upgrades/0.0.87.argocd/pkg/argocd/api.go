@@ -6,16 +6,26 @@ import (
 	"fmt"
 	"github.com/miekg/dns"
 	merrors "github.com/mishudark/errors"
-	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/checkpoint"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/clusterguard"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/info"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/kubeclient"
 	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/rbac"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
 	"github.com/oslokommune/okctl/pkg/api"
+	"github.com/oslokommune/okctl/pkg/apis/okctl.io/v1alpha1"
 	"github.com/oslokommune/okctl/pkg/cfn"
 	"github.com/oslokommune/okctl/pkg/client"
-	"github.com/oslokommune/okctl/pkg/client/core"
 	"github.com/oslokommune/okctl/pkg/config/constant"
 	"github.com/oslokommune/okctl/pkg/controller/common/reconciliation"
 	"github.com/oslokommune/okctl/pkg/helm/charts/argocd"
 	"github.com/oslokommune/okctl/pkg/okctl"
+	"k8s.io/client-go/kubernetes"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -36,63 +46,84 @@ const (
 	appVersionAfterUpgrade  = "v2.1.7"
 )
 
+// Info describes the upgrade for --info. TargetVersion and ID must match the name of the upgrade's directory.
+func Info() info.Info {
+	return info.Info{
+		TargetVersion: "0.0.87",
+		ID:            "argocd",
+		Components:    []string{"argocd"},
+		Destructive:   true,
+		RequiredEnv:   []string{"KUBECONFIG", constant.EnvClusterDeclaration},
+		Warning: fmt.Sprintf("ArgoCD %s is uninstalled and ArgoCD %s is installed in its place. ArgoCD is "+
+			"unavailable while the upgrade runs.", appVersionBeforeUpgrade, appVersionAfterUpgrade),
+	}
+}
+
 // ArgoCD is a sample okctl component
 type ArgoCD struct {
-	flags   cmdflags.Flags
 	okctl   OkctlTools
 	log     logger.Logger
 	kubectl Kubectl
 }
 
+// OkctlTools contains the okctl configuration, state and services the upgrade uses
 type OkctlTools struct {
-	o         *okctl.Okctl
-	clusterID api.ID
-	state     *core.StateHandlers
-	services  *core.Services
+	o           *okctl.Okctl
+	declaration *v1alpha1.Cluster
+	clusterID   api.ID
+	state       State
+	services    Services
+	secrets     SecretReader
 }
 
-var errNothingToDo = errors.New("nothing to do")
-
-// Upgrade upgrades the component
-func (a ArgoCD) Upgrade() error {
-	err := a.preflight()
-	if err != nil {
-		if errors.Is(err, errNothingToDo) {
-			return nil
-		}
-
-		return fmt.Errorf("running preflight checks: %w", err)
-	}
-
-	a.log.Infof("Upgrading ArgoCD to version %s\n", appVersionAfterUpgrade)
-
-	err = a.partiallyRemoveArgoCD()
-	if err != nil {
-		return fmt.Errorf("removing ArgoCD: %w", err)
-	}
-
-	err = a.createArgoCD()
-	if err != nil {
-		return fmt.Errorf("creating ArgoCD: %w", err)
-	}
-
-	err = a.postflight()
-	if err != nil {
-		return fmt.Errorf("running postflight checks: %w", err)
+// Upgrade returns the steps needed to upgrade ArgoCD
+func (a ArgoCD) Upgrade() engine.Upgrade {
+	return engine.Upgrade{
+		Name:          "ArgoCD",
+		Preflight:     a.preflight,
+		Preconditions: a.preconditions,
+		Steps: []engine.Step{
+			{
+				// Delete Helm release so we can reinstall it with correct version
+				Name:        "delete-helm-release",
+				Plan:        a.planDeleteHelmRelease,
+				Apply:       a.deleteHelmReleaseIfExists,
+				Destructive: true,
+				// The release is installed again on rollback
+				Permissions: chartPermissions("delete", "create"),
+			},
+			{
+				// Delete secrets because their format has changed
+				Name:        "delete-secrets",
+				Plan:        a.planDeleteSecrets,
+				Apply:       a.deleteSecrets,
+				Destructive: true,
+				Permissions: externalSecretPermissions("delete", "create"),
+			},
+			{
+				Name:        "wait-for-ingress-deletion",
+				Plan:        a.planWaitForIngress,
+				Apply:       a.waitForIngressToNotExist,
+				Permissions: ingressPermissions(),
+			},
+			{
+				Name:        "create-argocd",
+				Plan:        a.planCreateArgoCD,
+				Apply:       a.createArgoCD,
+				Verify:      a.postflight,
+				Permissions: createArgoCDPermissions(),
+			},
+		},
 	}
-
-	a.log.Info("Upgrading ArgoCD done!")
-
-	return nil
 }
 
 func (a ArgoCD) preflight() error {
-	if !a.okctl.o.Declaration.Integrations.ArgoCD {
+	if !a.okctl.declaration.Integrations.ArgoCD {
 		a.log.Info("ArgoCD is not enabled in cluster declaration, not doing anything")
-		return errNothingToDo
+		return commonerrors.ErrNothingToDo
 	}
 
-	release, err := getHelmRelease(a.okctl.o, argocd.ReleaseName, argocd.Namespace)
+	release, err := a.getHelmRelease()
 	if err != nil {
 		if merrors.IsKind(err, merrors.NotExist) {
 			return nil
@@ -105,53 +136,51 @@ func (a ArgoCD) preflight() error {
 	if currentVersion != appVersionBeforeUpgrade {
 		a.log.Infof("Current chart version is %s. This upgrade only targets chart version %s. Ignoring upgrade.\n",
 			currentVersion, appVersionBeforeUpgrade)
-		return errNothingToDo
+		return commonerrors.ErrNothingToDo
 	}
 
+	a.log.Debugf("Upgrading ArgoCD to version %s\n", appVersionAfterUpgrade)
+
 	return nil
 }
 
-func (a ArgoCD) partiallyRemoveArgoCD() error {
-	// Delete Helm release so we can reinstall it with correct version
-	err := a.deleteHelmReleaseIfExists()
-	if err != nil {
-		return fmt.Errorf("deleting helm release if exists: %w", err)
-	}
+// preconditions returns the ArgoCD version a saved plan was made for. The version is empty if ArgoCD is not installed.
+func (a ArgoCD) preconditions() (map[string]string, error) {
+	appVersion := ""
 
-	// Delete secrets because their format has changed
-	err = a.deleteSecrets()
-	if err != nil {
-		return fmt.Errorf("deleting secrets: %w", err)
+	release, err := a.getHelmRelease()
+	if err != nil && !merrors.IsKind(err, merrors.NotExist) {
+		return nil, fmt.Errorf("getting helm release: %w", err)
 	}
 
-	err = a.waitForIngressToNotExist()
-	if err != nil {
-		return fmt.Errorf("waiting for ingress to not exist: %w", err)
+	if err == nil {
+		appVersion = getHelmReleaseAppVersion(release)
 	}
 
-	return nil
+	return map[string]string{
+		"argocdEnabled":    strconv.FormatBool(a.okctl.declaration.Integrations.ArgoCD),
+		"argocdAppVersion": appVersion,
+	}, nil
 }
 
-func (a ArgoCD) deleteHelmReleaseIfExists() error {
-	releaseExists := false
-	_, err := getHelmRelease(a.okctl.o, argocd.ReleaseName, argocd.Namespace)
-	if err == nil {
-		releaseExists = true
-	} else if !merrors.IsKind(err, merrors.NotExist) {
+func (a ArgoCD) deleteHelmReleaseIfExists(undo *engine.Undo) error {
+	release, err := a.getHelmRelease()
+	if err != nil {
+		if merrors.IsKind(err, merrors.NotExist) {
+			a.log.Info("Helm release doesn't exist, skipping delete")
+			return nil
+		}
+
 		return fmt.Errorf("getting helm release: %w", err)
 	}
 
-	if !releaseExists {
-		a.log.Info("Helm release doesn't exist, skipping delete")
-		return nil
+	restoreRelease, err := a.prepareHelmReleaseRestore(release)
+	if err != nil {
+		return fmt.Errorf("preparing rollback of helm release: %w", err)
 	}
 
 	a.log.Info("Deleting Helm Release")
 
-	if a.flags.DryRun {
-		return nil
-	}
-
 	err = a.okctl.services.Helm.DeleteHelmRelease(context.Background(), client.DeleteHelmReleaseOpts{
 		ID:          a.okctl.clusterID,
 		ReleaseName: argocd.ReleaseName,
@@ -161,18 +190,23 @@ func (a ArgoCD) deleteHelmReleaseIfExists() error {
 		return fmt.Errorf("deleting helm release: %w", err)
 	}
 
+	restoreRelease.addTo(undo)
+
 	return nil
 }
 
-func (a ArgoCD) deleteSecrets() error {
+func (a ArgoCD) deleteSecrets(undo *engine.Undo) error {
 	a.log.Info("Deleting secrets")
-	if a.flags.DryRun {
-		return nil
-	}
 
 	for _, name := range []string{argoSecretName, argoPrivateKeyName} {
-		a.log.Debugf("Deleting external secret '%s'\n", name)
-		err := a.okctl.services.Manifest.DeleteExternalSecret(context.Background(), client.DeleteExternalSecretOpts{
+		restoreSecret, err := a.prepareExternalSecretRestore(name)
+		if err != nil {
+			return fmt.Errorf("preparing rollback of external secret: %w", err)
+		}
+
+		a.log.With("resource", namespaced(constant.DefaultArgoCDNamespace, name)).
+			Debugf("Deleting external secret '%s'\n", name)
+		err = a.okctl.services.Manifest.DeleteExternalSecret(context.Background(), client.DeleteExternalSecretOpts{
 			ID:   a.okctl.clusterID,
 			Name: name,
 			Secrets: map[string]string{
@@ -182,17 +216,26 @@ func (a ArgoCD) deleteSecrets() error {
 		if err != nil {
 			return fmt.Errorf("deleting external secret: %w", err)
 		}
+
+		restoreSecret.addTo(undo)
 	}
 
 	for _, secret := range []string{argoSecretKeyName, argoClientSecretName} {
-		a.log.Debugf("Deleting secret '%s'\n", secret)
-		err := a.okctl.services.Parameter.DeleteSecret(context.Background(), client.DeleteSecretOpts{
+		restoreSecret, err := a.prepareSecretRestore(secret)
+		if err != nil {
+			return fmt.Errorf("preparing rollback of secret: %w", err)
+		}
+
+		a.log.With("resource", secret).Debugf("Deleting secret '%s'\n", secret)
+		err = a.okctl.services.Parameter.DeleteSecret(context.Background(), client.DeleteSecretOpts{
 			ID:   a.okctl.clusterID,
 			Name: secret,
 		})
 		if err != nil {
 			return fmt.Errorf("deleting secret: %w", err)
 		}
+
+		restoreSecret.addTo(undo)
 	}
 
 	return nil
@@ -200,13 +243,10 @@ func (a ArgoCD) deleteSecrets() error {
 
 // The ingress takes a while to delete because the AWS ALB takes some time to remove. If we don't wait for it to be removed,
 // the new ingress won't be created.
-func (a ArgoCD) waitForIngressToNotExist() error {
+func (a ArgoCD) waitForIngressToNotExist(_ *engine.Undo) error {
 	const waitMsg = "Waiting for ArgoCD ingress to disappear"
 
 	a.log.Info(waitMsg)
-	if a.flags.DryRun {
-		return nil
-	}
 
 	secondsPassed := 0
 
@@ -232,7 +272,7 @@ func (a ArgoCD) waitForIngressToNotExist() error {
 	return nil
 }
 
-func (a ArgoCD) createArgoCD() error {
+func (a ArgoCD) createArgoCD(undo *engine.Undo) error {
 	// The following code is mostly copy pasted from argocd_reconciler.go
 	hostedZone, err := a.okctl.state.Domain.GetPrimaryHostedZone()
 	if err != nil {
@@ -240,7 +280,7 @@ func (a ArgoCD) createArgoCD() error {
 	}
 
 	identityPool, err := a.okctl.state.IdentityManager.GetIdentityPool(
-		cfn.NewStackNamer().IdentityPool(a.okctl.o.Declaration.Metadata.Name),
+		cfn.NewStackNamer().IdentityPool(a.okctl.declaration.Metadata.Name),
 	)
 	if err != nil {
 		return fmt.Errorf("getting identity pool: %w", err)
@@ -248,16 +288,14 @@ func (a ArgoCD) createArgoCD() error {
 
 	a.log.Info("Creating ArgoCD")
 
-	if a.flags.DryRun {
-		return nil
-	}
+	undo.Add("delete new ArgoCD Helm release", a.deleteNewHelmRelease)
 
 	a.log.Debug("CreateGithubRepository")
 	repo, err := a.okctl.services.Github.CreateGithubRepository(context.Background(), client.CreateGithubRepositoryOpts{
-		ID:           reconciliation.ClusterMetaAsID(a.okctl.o.Declaration.Metadata),
+		ID:           reconciliation.ClusterMetaAsID(a.okctl.declaration.Metadata),
 		Host:         constant.DefaultGithubHost,
-		Organization: a.okctl.o.Declaration.Github.Organisation,
-		Name:         a.okctl.o.Declaration.Github.Repository,
+		Organization: a.okctl.declaration.Github.Organisation,
+		Name:         a.okctl.declaration.Github.Repository,
 	})
 	if err != nil {
 		return fmt.Errorf("fetching deploy key: %w", err)
@@ -266,10 +304,10 @@ func (a ArgoCD) createArgoCD() error {
 	a.log.Debug("CreateArgoCD")
 	_, err = a.okctl.services.ArgoCD.CreateArgoCD(context.Background(), client.CreateArgoCDOpts{
 		ID:                 a.okctl.clusterID,
-		Domain:             a.okctl.o.Declaration.ClusterRootDomain,
-		FQDN:               dns.Fqdn(a.okctl.o.Declaration.ClusterRootDomain),
+		Domain:             a.okctl.declaration.ClusterRootDomain,
+		FQDN:               dns.Fqdn(a.okctl.declaration.ClusterRootDomain),
 		HostedZoneID:       hostedZone.HostedZoneID,
-		GithubOrganisation: a.okctl.o.Declaration.Github.Organisation,
+		GithubOrganisation: a.okctl.declaration.Github.Organisation,
 		UserPoolID:         identityPool.UserPoolID,
 		AuthDomain:         identityPool.AuthDomain,
 		Repository:         repo,
@@ -288,11 +326,7 @@ func (a ArgoCD) createArgoCD() error {
 func (a ArgoCD) postflight() error {
 	a.log.Info("Verifying new ArgoCD version")
 
-	if a.flags.DryRun {
-		return nil
-	}
-
-	release, err := getHelmRelease(a.okctl.o, argocd.ReleaseName, argocd.Namespace)
+	release, err := a.getHelmRelease()
 	if err != nil {
 		return fmt.Errorf("getting helm release: %w", err)
 	}
@@ -314,8 +348,76 @@ func (a ArgoCD) postflight() error {
 	return nil
 }
 
-func New(log logger.Logger, flags cmdflags.Flags) (ArgoCD, error) {
-	kubectl, err := newKubectl(log)
+// Checkpoints returns where the progress of the upgrade is stored, so an interrupted upgrade can be resumed. Returns
+// nil if the okctl tools have no okctl environment to store it in, as with NewOkctlTools.
+func (a ArgoCD) Checkpoints() (engine.Checkpointer, error) {
+	if a.okctl.o == nil {
+		return nil, nil
+	}
+
+	checkpointPath, err := getCheckpointPath(a.okctl.o)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring checkpoint path: %w", err)
+	}
+
+	return checkpoint.NewFile(checkpointPath), nil
+}
+
+// CheckCluster refuses to run if KUBECONFIG or the AWS credentials point at another cluster than the cluster
+// declaration, and returns the name of the cluster. It only reads the cluster declaration and the environment, so that
+// it can run before anything connects to the cluster or opens the state.
+func CheckCluster() (string, error) {
+	declaration, err := declarationFromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	env, err := clusterguard.FromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	err = clusterguard.Check(clusterguard.Cluster{
+		Name:      declaration.Metadata.Name,
+		Region:    declaration.Metadata.Region,
+		AccountID: declaration.Metadata.AccountID,
+	}, env)
+	if err != nil {
+		return "", engine.PreflightError{Err: err}
+	}
+
+	return declaration.Metadata.Name, nil
+}
+
+// Access checks the user's permissions in the cluster in KUBECONFIG
+func (a ArgoCD) Access() rbac.Checker {
+	return rbac.New(a.kubectl.clientSet)
+}
+
+// Blocked returns the Kubernetes requests that were refused because they would have changed the cluster in dry-run mode
+func (a ArgoCD) Blocked() []string {
+	return a.kubectl.Blocked()
+}
+
+// Opts contains the flags the upgrade needs to know about
+type Opts struct {
+	// DryRun makes the Kubernetes client refuse to change the cluster
+	DryRun bool
+
+	// Trace records every Kubernetes request and okctl service call. Optional.
+	Trace *trace.Recorder
+
+	// Record saves the requests made by the Kubernetes client to a cassette, for replaying in tests. okctl's services
+	// use their own clients, so their requests are not recorded. Optional.
+	Record *replay.Recorder
+}
+
+func New(log logger.Logger, opts Opts) (ArgoCD, error) {
+	kubectl, err := newKubectl(log, kubeclient.Opts{
+		DryRun: opts.DryRun,
+		Trace:  opts.Trace,
+		Record: opts.Record,
+	})
 	if err != nil {
 		return ArgoCD{}, fmt.Errorf("creating kubectl: %w", err)
 	}
@@ -333,16 +435,55 @@ func New(log logger.Logger, flags cmdflags.Flags) (ArgoCD, error) {
 	}
 
 	okctlTools := OkctlTools{
-		o:         o,
-		clusterID: getClusterID(o),
-		state:     state,
-		services:  services,
+		o:           o,
+		declaration: o.Declaration,
+		clusterID:   getClusterID(o),
+		state: State{
+			Helm:            state.Helm,
+			Manifest:        state.Manifest,
+			Parameter:       state.Parameter,
+			Domain:          state.Domain,
+			IdentityManager: state.IdentityManager,
+		},
+		services: traceServices(Services{
+			Helm:      services.Helm,
+			Manifest:  services.Manifest,
+			Parameter: services.Parameter,
+			Github:    services.Github,
+			ArgoCD:    services.ArgoCD,
+		}, opts.Trace),
+		secrets: ssmSecretReader{ssm: o.CloudProvider.SSM()},
+	}
+
+	return newArgoCD(log, kubectl, okctlTools), nil
+}
+
+// NewOkctlTools returns okctl tools using the given cluster declaration, state and services instead of the ones in the
+// okctl environment, such as the fakes in argocdtest
+func NewOkctlTools(declaration *v1alpha1.Cluster, state State, services Services, secrets SecretReader) OkctlTools {
+	return OkctlTools{
+		declaration: declaration,
+		clusterID: api.ID{
+			Region:       declaration.Metadata.Region,
+			AWSAccountID: declaration.Metadata.AccountID,
+			ClusterName:  declaration.Metadata.Name,
+		},
+		state:    state,
+		services: services,
+		secrets:  secrets,
 	}
+}
+
+// NewWithTools returns an upgrade using the given cluster and okctl tools instead of the ones in the environment, such
+// as the ones from NewOkctlTools
+func NewWithTools(log logger.Logger, clientSet kubernetes.Interface, okctlTools OkctlTools) ArgoCD {
+	return newArgoCD(log, Kubectl{logger: log, clientSet: clientSet}, okctlTools)
+}
 
+func newArgoCD(log logger.Logger, kubectl Kubectl, okctlTools OkctlTools) ArgoCD {
 	return ArgoCD{
-		flags:   flags,
 		okctl:   okctlTools,
 		log:     log,
 		kubectl: kubectl,
-	}, nil
+	}
 }
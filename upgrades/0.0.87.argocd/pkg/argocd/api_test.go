@@ -0,0 +1,175 @@
+package argocd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd/argocdtest"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/rbac"
+)
+
+func TestUpgrade(t *testing.T) {
+	testCases := []struct {
+		name              string
+		installedVersion  string
+		failOn            string
+		releaseNotInState bool
+		deny              []rbac.Permission
+		expectErr         error
+		expectCalls       []string
+		expectVersion     string
+	}{
+		{
+			name:             "Should reinstall ArgoCD with new secrets",
+			installedVersion: appVersionBeforeUpgrade,
+			expectCalls: []string{
+				"delete helm release argocd",
+				"delete external secret argocd-secret",
+				"delete external secret argocd-privatekey",
+				"delete secret argocd/secret_key",
+				"delete secret argocd/client_secret",
+				"create github repository oslokommune/test-iac",
+				"create argocd test.example.com",
+			},
+			expectVersion: appVersionAfterUpgrade,
+		},
+		{
+			name:             "Should do nothing when ArgoCD is already upgraded",
+			installedVersion: appVersionAfterUpgrade,
+			expectCalls:      nil,
+			expectVersion:    appVersionAfterUpgrade,
+		},
+		{
+			name:             "Should restore deleted release and secrets when installing the new ArgoCD fails",
+			installedVersion: appVersionBeforeUpgrade,
+			failOn:           "create argocd test.example.com",
+			expectErr:        argocdtest.ErrFake,
+			expectCalls: []string{
+				"delete helm release argocd",
+				"delete external secret argocd-secret",
+				"delete external secret argocd-privatekey",
+				"delete secret argocd/secret_key",
+				"delete secret argocd/client_secret",
+				"create github repository oslokommune/test-iac",
+				"create argocd test.example.com",
+				// Rollback, in reverse order. argocd-privatekey is not in the state, so it can't be restored.
+				"delete helm release argocd",
+				"create secret argocd/client_secret",
+				"create secret argocd/secret_key",
+				"delete external secret argocd-secret",
+				"create external secret argocd-secret",
+				"create helm release argocd",
+			},
+			expectVersion: appVersionBeforeUpgrade,
+		},
+		{
+			name:             "Should not restore a secret that failed to be deleted",
+			installedVersion: appVersionBeforeUpgrade,
+			failOn:           "delete secret argocd/client_secret",
+			expectErr:        argocdtest.ErrFake,
+			expectCalls: []string{
+				"delete helm release argocd",
+				"delete external secret argocd-secret",
+				"delete external secret argocd-privatekey",
+				"delete secret argocd/secret_key",
+				"delete secret argocd/client_secret",
+				// Rollback, in reverse order. argocd/client_secret still exists, so it isn't created again.
+				"create secret argocd/secret_key",
+				"delete external secret argocd-secret",
+				"create external secret argocd-secret",
+				"create helm release argocd",
+			},
+			expectVersion: appVersionBeforeUpgrade,
+		},
+		{
+			name:              "Should roll back without the release when the release isn't in the state",
+			installedVersion:  appVersionBeforeUpgrade,
+			failOn:            "create argocd test.example.com",
+			releaseNotInState: true,
+			expectErr:         argocdtest.ErrFake,
+			expectCalls: []string{
+				"delete helm release argocd",
+				"delete external secret argocd-secret",
+				"delete external secret argocd-privatekey",
+				"delete secret argocd/secret_key",
+				"delete secret argocd/client_secret",
+				"create github repository oslokommune/test-iac",
+				"create argocd test.example.com",
+				"delete helm release argocd",
+				"create secret argocd/client_secret",
+				"create secret argocd/secret_key",
+				"delete external secret argocd-secret",
+				"create external secret argocd-secret",
+			},
+			expectVersion: "",
+		},
+		{
+			name:             "Should change nothing when the user isn't allowed to delete the ingress",
+			installedVersion: appVersionBeforeUpgrade,
+			deny: []rbac.Permission{
+				{Verb: "delete", Group: "networking.k8s.io", Resource: "ingresses", Namespace: argoCDNamespace},
+			},
+			expectErr:     rbac.ErrMissingPermissions,
+			expectCalls:   nil,
+			expectVersion: appVersionBeforeUpgrade,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			argoCD, fake := newTestArgoCD(t, tc.installedVersion)
+			fake.FailOn = tc.failOn
+			fake.ReleaseNotInState = tc.releaseNotInState
+			fake.cluster.Deny(tc.deny...)
+
+			e := engine.New(logger.New(logger.Error), engine.Opts{
+				Confirm:        true,
+				AcceptDataLoss: true,
+				Access:         argoCD.Access(),
+			})
+
+			_, err := e.Run(argoCD.Upgrade())
+			if !errors.Is(err, tc.expectErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+			}
+
+			if !reflect.DeepEqual(fake.Calls, tc.expectCalls) {
+				t.Errorf("expected calls\n%v\ngot\n%v", tc.expectCalls, fake.Calls)
+			}
+
+			version := ""
+			if fake.Release != nil {
+				version = getHelmReleaseAppVersion(fake.Release)
+			}
+
+			if version != tc.expectVersion {
+				t.Errorf("expected ArgoCD version %s, got %s", tc.expectVersion, version)
+			}
+		})
+	}
+}
+
+func TestDeleteSecretsFailure(t *testing.T) {
+	argoCD, fake := newTestArgoCD(t, appVersionBeforeUpgrade)
+	fake.FailOn = "delete secret argocd/client_secret"
+
+	undo := &engine.Undo{}
+
+	err := argoCD.deleteSecrets(undo)
+	if !errors.Is(err, argocdtest.ErrFake) {
+		t.Fatalf("expected error %v, got %v", argocdtest.ErrFake, err)
+	}
+
+	if fake.Secrets[argoClientSecretName] != true {
+		t.Errorf("expected secret %s to still exist", argoClientSecretName)
+	}
+
+	if fake.Secrets[argoSecretKeyName] != false {
+		t.Errorf("expected secret %s to be deleted", argoSecretKeyName)
+	}
+}
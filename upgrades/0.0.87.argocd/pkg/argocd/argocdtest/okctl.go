@@ -0,0 +1,351 @@
+// Package argocdtest contains fake okctl services and state, for testing the upgrade without an okctl environment.
+// Pass them to argocd.NewOkctlTools.
+package argocdtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	stormpkg "github.com/asdine/storm/v3"
+	merrors "github.com/mishudark/errors"
+	"github.com/oslokommune/okctl/pkg/apis/okctl.io/v1alpha1"
+	"github.com/oslokommune/okctl/pkg/client"
+	"github.com/oslokommune/okctl/pkg/helm"
+	"github.com/oslokommune/okctl/pkg/helm/charts/argocd"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// These must match the names and versions in the argocd package
+const (
+	// AppVersionBeforeUpgrade is the ArgoCD version the upgrade replaces
+	AppVersionBeforeUpgrade = "v1.6.2"
+
+	// AppVersionAfterUpgrade is the ArgoCD version the upgrade installs
+	AppVersionAfterUpgrade = "v2.1.7"
+
+	ingressName          = "argocd-server"
+	clientSecretName     = "argocd/client_secret" //nolint:gosec
+	secretKeyName        = "argocd/secret_key"    //nolint:gosec
+	externalSecretName   = "argocd-secret"
+	privateKeySecretName = "argocd-privatekey"
+)
+
+// ErrFake is returned by the call in Okctl.FailOn
+var ErrFake = errors.New("fake error")
+
+// Okctl keeps okctl's services and state in memory, and records every call that changes something
+type Okctl struct {
+	// Calls describes every call that changed something, in order
+	Calls []string
+
+	Release         *client.Helm
+	ExternalSecrets map[string]bool
+	Secrets         map[string]bool
+
+	// FailOn makes the call with this description fail
+	FailOn string
+
+	// ReleaseNotInState leaves the Helm release out of the state, as if it was installed without okctl
+	ReleaseNotInState bool
+
+	// clientSet is changed like okctl's services change the cluster
+	clientSet kubernetes.Interface
+}
+
+// Snapshot is the state of the fake okctl services at one point in time
+type Snapshot struct {
+	AppVersion      string
+	ExternalSecrets map[string]bool
+	Secrets         map[string]bool
+}
+
+// Snapshot returns the current state of the fake okctl services
+func (f *Okctl) Snapshot() Snapshot {
+	s := Snapshot{
+		ExternalSecrets: make(map[string]bool, len(f.ExternalSecrets)),
+		Secrets:         make(map[string]bool, len(f.Secrets)),
+	}
+
+	if f.Release != nil {
+		s.AppVersion = f.Release.Release.Chart.Metadata.AppVersion
+	}
+
+	for name := range f.ExternalSecrets {
+		s.ExternalSecrets[name] = true
+	}
+
+	for name := range f.Secrets {
+		s.Secrets[name] = true
+	}
+
+	return s
+}
+
+func (f *Okctl) record(format string, args ...interface{}) error {
+	call := fmt.Sprintf(format, args...)
+	f.Calls = append(f.Calls, call)
+
+	if call == f.FailOn {
+		return fmt.Errorf("%s: %w", call, ErrFake)
+	}
+
+	return nil
+}
+
+// HelmService is a fake of okctl's Helm service
+type HelmService struct{ *Okctl }
+
+func (f HelmService) CreateHelmRelease(_ context.Context, opts client.CreateHelmReleaseOpts) (*client.Helm, error) {
+	err := f.record("create helm release %s", opts.ReleaseName)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Release = newHelmRelease(AppVersionBeforeUpgrade)
+
+	return f.Release, nil
+}
+
+// DeleteHelmRelease uninstalls the release, which removes the ArgoCD ingress from the fake cluster
+func (f HelmService) DeleteHelmRelease(_ context.Context, opts client.DeleteHelmReleaseOpts) error {
+	err := f.record("delete helm release %s", opts.ReleaseName)
+	if err != nil {
+		return err
+	}
+
+	f.Release = nil
+
+	err = f.clientSet.NetworkingV1().Ingresses(argocd.Namespace).Delete(context.Background(),
+		ingressName, metav1.DeleteOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (f HelmService) GetHelmRelease(_ context.Context, opts client.GetHelmReleaseOpts) (*client.Helm, error) {
+	if f.Release == nil {
+		return nil, merrors.E(fmt.Errorf("helm release %s not found", opts.ReleaseName), merrors.NotExist)
+	}
+
+	return f.Release, nil
+}
+
+// ManifestService is a fake of okctl's manifest service
+type ManifestService struct{ *Okctl }
+
+func (f ManifestService) CreateExternalSecret(
+	_ context.Context,
+	opts client.CreateExternalSecretOpts,
+) (*client.KubernetesManifest, error) {
+	err := f.record("create external secret %s", opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.ExternalSecrets[opts.Name] = true
+
+	return &client.KubernetesManifest{Name: opts.Name, Namespace: opts.Namespace}, nil
+}
+
+func (f ManifestService) DeleteExternalSecret(_ context.Context, opts client.DeleteExternalSecretOpts) error {
+	err := f.record("delete external secret %s", opts.Name)
+	if err != nil {
+		return err
+	}
+
+	delete(f.ExternalSecrets, opts.Name)
+
+	return nil
+}
+
+// ParameterService is a fake of okctl's parameter service
+type ParameterService struct{ *Okctl }
+
+func (f ParameterService) CreateSecret(_ context.Context, opts client.CreateSecretOpts) (*client.SecretParameter, error) {
+	err := f.record("create secret %s", opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Secrets[opts.Name] = true
+
+	return &client.SecretParameter{Name: opts.Name}, nil
+}
+
+func (f ParameterService) DeleteSecret(_ context.Context, opts client.DeleteSecretOpts) error {
+	err := f.record("delete secret %s", opts.Name)
+	if err != nil {
+		return err
+	}
+
+	delete(f.Secrets, opts.Name)
+
+	return nil
+}
+
+// GithubService is a fake of okctl's Github service
+type GithubService struct{ *Okctl }
+
+func (f GithubService) CreateGithubRepository(
+	_ context.Context,
+	opts client.CreateGithubRepositoryOpts,
+) (*client.GithubRepository, error) {
+	err := f.record("create github repository %s/%s", opts.Organization, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.GithubRepository{Organisation: opts.Organization, Repository: opts.Name}, nil
+}
+
+// ArgoCDService installs the new ArgoCD release, and creates its ingress in the fake cluster
+type ArgoCDService struct{ *Okctl }
+
+func (f ArgoCDService) CreateArgoCD(_ context.Context, opts client.CreateArgoCDOpts) (*client.ArgoCD, error) {
+	err := f.record("create argocd %s", opts.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Release = newHelmRelease(AppVersionAfterUpgrade)
+
+	_, err = f.clientSet.NetworkingV1().Ingresses(argocd.Namespace).Create(context.Background(), &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ingressName,
+			Namespace: argocd.Namespace,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &client.ArgoCD{}, nil
+}
+
+// HelmState is a fake of okctl's Helm state
+type HelmState struct{ *Okctl }
+
+func (f HelmState) GetHelmRelease(releaseName string) (*client.Helm, error) {
+	if f.ReleaseNotInState {
+		return nil, stormpkg.ErrNotFound
+	}
+
+	return &client.Helm{
+		Chart: &helm.Chart{
+			RepositoryName: "argo",
+			RepositoryURL:  "https://argoproj.github.io/argo-helm",
+			ReleaseName:    releaseName,
+			Version:        "2.13.0",
+			Chart:          "argo-cd",
+			Namespace:      argocd.Namespace,
+		},
+	}, nil
+}
+
+// ManifestState is a fake of okctl's manifest state, without the private key secret
+type ManifestState struct{}
+
+func (ManifestState) GetKubernetesManifests(name string) (*client.KubernetesManifest, error) {
+	if name == privateKeySecretName {
+		return nil, stormpkg.ErrNotFound
+	}
+
+	return &client.KubernetesManifest{
+		Name:      name,
+		Namespace: argocd.Namespace,
+		Content: []byte(fmt.Sprintf(`apiVersion: kubernetes-client.io/v1
+kind: ExternalSecret
+metadata:
+  name: %s
+  namespace: argocd
+spec:
+  backendType: systemManager
+  data:
+    - key: /okctl/test/argocd/secret_key
+      name: server.secretkey
+`, name)),
+	}, nil
+}
+
+// ParameterState is a fake of okctl's parameter state
+type ParameterState struct{}
+
+func (ParameterState) GetSecret(name string) (*client.SecretParameter, error) {
+	return &client.SecretParameter{Name: name, Path: "/okctl/test/" + name}, nil
+}
+
+// DomainState is a fake of okctl's domain state
+type DomainState struct{}
+
+func (DomainState) GetPrimaryHostedZone() (*client.HostedZone, error) {
+	return &client.HostedZone{HostedZoneID: "Z123"}, nil
+}
+
+// IdentityManagerState is a fake of okctl's identity manager state
+type IdentityManagerState struct{}
+
+func (IdentityManagerState) GetIdentityPool(_ string) (*client.IdentityPool, error) {
+	return &client.IdentityPool{UserPoolID: "pool", AuthDomain: "auth.test.example.com"}, nil
+}
+
+// SecretReader returns a fake value for every secret
+type SecretReader struct{}
+
+func (SecretReader) GetSecretValue(path string) (string, error) {
+	return "value of " + path, nil
+}
+
+func newHelmRelease(appVersion string) *client.Helm {
+	return &client.Helm{
+		Release: &release.Release{
+			Name: argocd.ReleaseName,
+			Chart: &chart.Chart{
+				Metadata: &chart.Metadata{AppVersion: appVersion},
+			},
+			Config: map[string]interface{}{},
+		},
+	}
+}
+
+// New returns fake okctl services with ArgoCD installed in the given version, or not installed if empty. The services
+// change the given cluster like okctl's would.
+func New(clientSet kubernetes.Interface, installedVersion string) *Okctl {
+	fake := &Okctl{
+		ExternalSecrets: map[string]bool{externalSecretName: true, privateKeySecretName: true},
+		Secrets:         map[string]bool{secretKeyName: true, clientSecretName: true},
+		clientSet:       clientSet,
+	}
+
+	if installedVersion != "" {
+		fake.Release = newHelmRelease(installedVersion)
+	}
+
+	return fake
+}
+
+// Declaration returns a cluster declaration with ArgoCD enabled
+func Declaration() *v1alpha1.Cluster {
+	return &v1alpha1.Cluster{
+		Metadata: v1alpha1.ClusterMeta{
+			Name:      "test",
+			Region:    "eu-west-1",
+			AccountID: "123456789012",
+		},
+		Github: v1alpha1.ClusterGithub{
+			Organisation: "oslokommune",
+			Repository:   "test-iac",
+		},
+		ClusterRootDomain: "test.example.com",
+		Integrations: &v1alpha1.ClusterIntegrations{
+			ArgoCD: true,
+		},
+	}
+}
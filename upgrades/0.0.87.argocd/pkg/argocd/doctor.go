@@ -0,0 +1,114 @@
+package argocd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/clusterguard"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/doctor"
+	"github.com/oslokommune/okctl/pkg/apis/okctl.io/v1alpha1"
+	"github.com/oslokommune/okctl/pkg/config/constant"
+	"github.com/oslokommune/okctl/pkg/okctl"
+	"os"
+	"path"
+)
+
+// DoctorChecks returns the prerequisites of the upgrade other than environment variables. Unlike initializeOkctl,
+// the checks don't create anything that is missing. With manageState, the local state doesn't have to exist, as it is
+// downloaded before the upgrade runs.
+func DoctorChecks(manageState bool) []doctor.Check {
+	return []doctor.Check{
+		{
+			Name: "Cluster declaration",
+			Run: func() (string, error) {
+				declaration, err := validDeclaration()
+				if err != nil {
+					return "", err
+				}
+
+				return declaration.Metadata.Name, nil
+			},
+			Fix: fmt.Sprintf("Set %s to the path of the cluster's declaration, and fix the errors okctl reports.",
+				constant.EnvClusterDeclaration),
+		},
+		{
+			Name: "okctl user data",
+			Run: func() (string, error) {
+				userDataPath, err := okctl.New().GetUserDataPath()
+				if err != nil {
+					return "", fmt.Errorf("acquiring user data path: %w", err)
+				}
+
+				_, err = os.Stat(userDataPath)
+				if err != nil {
+					return "", err
+				}
+
+				return userDataPath, nil
+			},
+			Fix: fmt.Sprintf("Run okctl venv once to create it, or set %s to the directory containing .okctl.",
+				constant.EnvHome),
+		},
+		{
+			Name: "Local state",
+			Run: func() (string, error) {
+				localStateDBPath, err := localStateDBPath()
+				if err != nil {
+					return "", err
+				}
+
+				_, err = os.Stat(localStateDBPath)
+				if errors.Is(err, os.ErrNotExist) && manageState {
+					return "downloaded by --manage-state", nil
+				}
+
+				if err != nil {
+					return "", err
+				}
+
+				return localStateDBPath, nil
+			},
+			Fix: "Run the upgrade with --manage-state, which downloads the state, or through okctl upgrade.",
+		},
+		doctor.Kubernetes(),
+		doctor.AWSCredentials(),
+		doctor.SameCluster(func() (clusterguard.Cluster, error) {
+			declaration, err := validDeclaration()
+			if err != nil {
+				return clusterguard.Cluster{}, err
+			}
+
+			return clusterguard.Cluster{
+				Name:      declaration.Metadata.Name,
+				Region:    declaration.Metadata.Region,
+				AccountID: declaration.Metadata.AccountID,
+			}, nil
+		}),
+	}
+}
+
+// validDeclaration reads and validates the cluster declaration like initializeDeclaration does
+func validDeclaration() (*v1alpha1.Cluster, error) {
+	o := okctl.New()
+
+	err := initializeDeclaration(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.Declaration, nil
+}
+
+// localStateDBPath returns the path getLocalStatePath would return, without creating its directory
+func localStateDBPath() (string, error) {
+	declaration, err := validDeclaration()
+	if err != nil {
+		return "", err
+	}
+
+	dataDir, err := okctl.New().GetUserDataDir()
+	if err != nil {
+		return "", fmt.Errorf("acquiring user data dir: %w", err)
+	}
+
+	return path.Join(dataDir, "localState", declaration.Metadata.Name, constant.DefaultStormDBName), nil
+}
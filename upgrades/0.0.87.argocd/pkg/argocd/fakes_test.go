@@ -0,0 +1,59 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd/argocdtest"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+)
+
+// testOkctl are the fake okctl services of a test, and the fake cluster they change
+type testOkctl struct {
+	*argocdtest.Okctl
+
+	cluster fakecluster.Cluster
+}
+
+// newTestArgoCD returns an ArgoCD upgrade using in-memory okctl services, and a fake cluster seeded with the given
+// fixtures. installedVersion is the ArgoCD version installed before the upgrade, or empty if none.
+func newTestArgoCD(t *testing.T, installedVersion string, fixtures ...string) (ArgoCD, testOkctl) {
+	t.Helper()
+
+	cluster := fakecluster.New(t, fixtures...)
+	fake := argocdtest.New(cluster.Clientset, installedVersion)
+
+	return NewWithTools(logger.New(logger.Error), cluster.Clientset, newFakeOkctlTools(fake)), testOkctl{
+		Okctl:   fake,
+		cluster: cluster,
+	}
+}
+
+// newFakeOkctlTools returns okctl tools using the given fake services
+func newFakeOkctlTools(fake *argocdtest.Okctl) OkctlTools {
+	return NewOkctlTools(argocdtest.Declaration(), State{
+		Helm:            argocdtest.HelmState{Okctl: fake},
+		Manifest:        argocdtest.ManifestState{},
+		Parameter:       argocdtest.ParameterState{},
+		Domain:          argocdtest.DomainState{},
+		IdentityManager: argocdtest.IdentityManagerState{},
+	}, Services{
+		Helm:      argocdtest.HelmService{Okctl: fake},
+		Manifest:  argocdtest.ManifestService{Okctl: fake},
+		Parameter: argocdtest.ParameterService{Okctl: fake},
+		Github:    argocdtest.GithubService{Okctl: fake},
+		ArgoCD:    argocdtest.ArgoCDService{Okctl: fake},
+	}, argocdtest.SecretReader{})
+}
+
+func TestFakeVersions(t *testing.T) {
+	if argocdtest.AppVersionBeforeUpgrade != appVersionBeforeUpgrade {
+		t.Errorf("expected the fakes to install ArgoCD %s, got %s", appVersionBeforeUpgrade,
+			argocdtest.AppVersionBeforeUpgrade)
+	}
+
+	if argocdtest.AppVersionAfterUpgrade != appVersionAfterUpgrade {
+		t.Errorf("expected the fakes to upgrade ArgoCD to %s, got %s", appVersionAfterUpgrade,
+			argocdtest.AppVersionAfterUpgrade)
+	}
+}
@@ -0,0 +1,19 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/idempotency"
+)
+
+func TestUpgradeIsIdempotent(t *testing.T) {
+	argoCD, fake := newTestArgoCD(t, appVersionBeforeUpgrade, "testdata/argocd-ingress.yaml")
+
+	idempotency.Run(t, idempotency.Subject{
+		Cluster: fake.cluster,
+		Upgrade: argoCD.Upgrade,
+		Snapshot: func() interface{} {
+			return fake.Snapshot()
+		},
+	})
+}
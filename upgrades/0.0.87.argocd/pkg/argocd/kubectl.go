@@ -2,15 +2,12 @@ package argocd
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"github.com/Masterminds/semver"
 	"k8s.io/api/networking/v1"
-	"os"
 	"strings"
 
-	"k8s.io/client-go/tools/clientcmd"
-
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/kubeclient"
 	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -22,7 +19,7 @@ const (
 
 type Kubectl struct {
 	logger    logger.Logger
-	clientSet *kubernetes.Clientset
+	clientSet kubernetes.Interface
 }
 
 func (k Kubectl) hasDeployment(namespace, deployment string) (bool, error) {
@@ -108,27 +105,17 @@ func int64Ptr(i int64) *int64 {
 	return &i
 }
 
-func acquireKubectlClient() (*kubernetes.Clientset, error) {
-	kubeConfigPath := os.Getenv("KUBECONFIG")
-	if kubeConfigPath == "" {
-		return nil, errors.New("missing required KUBECONFIG environment variable")
-	}
-
-	cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
-	if err != nil {
-		return nil, fmt.Errorf("creating rest config: %w", err)
-	}
-
-	client, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("initializing client: %w", err)
+// Blocked returns the requests that were refused because they would have changed the cluster in dry-run mode
+func (k Kubectl) Blocked() []string {
+	if client, ok := k.clientSet.(kubeclient.Client); ok {
+		return client.Blocked()
 	}
 
-	return client, nil
+	return nil
 }
 
-func newKubectl(logger logger.Logger) (Kubectl, error) {
-	clientSet, err := acquireKubectlClient()
+func newKubectl(logger logger.Logger, opts kubeclient.Opts) (Kubectl, error) {
+	clientSet, err := kubeclient.NewFromEnv(opts)
 	if err != nil {
 		return Kubectl{}, fmt.Errorf("aqcuiring kubectl client: %w", err)
 	}
@@ -0,0 +1,48 @@
+package argocd
+
+import (
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/fakecluster"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+)
+
+func TestHasIngress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fixtures []string
+		expect   bool
+	}{
+		{
+			name:     "Should find existing ingress",
+			fixtures: []string{"testdata/argocd-ingress.yaml"},
+			expect:   true,
+		},
+		{
+			name:   "Should not find missing ingress",
+			expect: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			cluster := fakecluster.New(t, tc.fixtures...)
+
+			kubectl := Kubectl{
+				logger:    logger.New(logger.Error),
+				clientSet: cluster.Clientset,
+			}
+
+			hasIngress, err := kubectl.hasIngress(argoCDNamespace, argoCDIngressName)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if hasIngress != tc.expect {
+				t.Errorf("expected ingress existence to be %t, got %t", tc.expect, hasIngress)
+			}
+		})
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/oslokommune/okctl/cmd/okctl/hooks"
 	"github.com/oslokommune/okctl/pkg/api"
+	"github.com/oslokommune/okctl/pkg/apis/okctl.io/v1alpha1"
 	"github.com/oslokommune/okctl/pkg/client"
 	"github.com/oslokommune/okctl/pkg/config/constant"
+	"github.com/oslokommune/okctl/pkg/helm/charts/argocd"
 	"github.com/oslokommune/okctl/pkg/okctl"
 	"github.com/spf13/cobra"
 	"os"
@@ -15,9 +17,27 @@ import (
 
 const (
 	localStatePathErrFormat = "acquiring local state path: %w"
+
+	upgradeVersion = "0.0.87.argocd"
 )
 
 func initializeOkctl() (*okctl.Okctl, error) {
+	o, err := initializeOkctlWithoutState()
+	if err != nil {
+		return nil, err
+	}
+
+	err = initializeState(o)
+	if err != nil {
+		return nil, fmt.Errorf("initializing state: %w", err)
+	}
+
+	return o, nil
+}
+
+// initializeOkctlWithoutState initializes okctl without requiring the local state to exist, which is needed for
+// downloading it
+func initializeOkctlWithoutState() (*okctl.Okctl, error) {
 	o := okctl.New()
 	cmd := &cobra.Command{}
 	args := []string{}
@@ -37,23 +57,13 @@ func initializeOkctl() (*okctl.Okctl, error) {
 		return nil, fmt.Errorf("initializing okctl: %w", err)
 	}
 
-	err = initializeState(o)
-	if err != nil {
-		return nil, fmt.Errorf("initializing state: %w", err)
-	}
-
 	return o, nil
 }
 
 func initializeDeclaration(o *okctl.Okctl) error {
-	clusterDeclarationPath := os.Getenv(constant.EnvClusterDeclaration)
-	if clusterDeclarationPath == "" {
-		return fmt.Errorf("missing required %s environment variable", constant.EnvClusterDeclaration)
-	}
-
-	declaration, err := readClusterDeclaration(clusterDeclarationPath)
+	declaration, err := declarationFromEnv()
 	if err != nil {
-		return fmt.Errorf("reading cluster declaration: %w", err)
+		return err
 	}
 
 	err = declaration.Validate()
@@ -66,6 +76,21 @@ func initializeDeclaration(o *okctl.Okctl) error {
 	return nil
 }
 
+// declarationFromEnv reads the cluster declaration okctl venv points to
+func declarationFromEnv() (*v1alpha1.Cluster, error) {
+	clusterDeclarationPath := os.Getenv(constant.EnvClusterDeclaration)
+	if clusterDeclarationPath == "" {
+		return nil, fmt.Errorf("missing required %s environment variable", constant.EnvClusterDeclaration)
+	}
+
+	declaration, err := readClusterDeclaration(clusterDeclarationPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster declaration: %w", err)
+	}
+
+	return declaration, nil
+}
+
 func initializeState(o *okctl.Okctl) error {
 	localStateDBPath, err := getLocalStatePath(o)
 	if err != nil {
@@ -99,6 +124,16 @@ func getLocalStatePath(o *okctl.Okctl) (string, error) {
 	return path.Join(dir, constant.DefaultStormDBName), nil
 }
 
+// getCheckpointPath returns the path of the file where the progress of this upgrade is stored, next to the local state
+func getCheckpointPath(o *okctl.Okctl) (string, error) {
+	localStateDBPath, err := getLocalStatePath(o)
+	if err != nil {
+		return "", fmt.Errorf(localStatePathErrFormat, err)
+	}
+
+	return path.Join(path.Dir(localStateDBPath), fmt.Sprintf("upgrade_%s_checkpoint.json", upgradeVersion)), nil
+}
+
 func getClusterID(o *okctl.Okctl) api.ID {
 	return api.ID{
 		Region:       o.Declaration.Metadata.Region,
@@ -107,24 +142,12 @@ func getClusterID(o *okctl.Okctl) api.ID {
 	}
 }
 
-func getHelmRelease(o *okctl.Okctl, releaseName, namespace string) (*client.Helm, error) {
-	state := o.StateHandlers(o.StateNodes())
-
-	services, err := o.ClientServices(state)
-	if err != nil {
-		return nil, err
-	}
-
-	release, err := services.Helm.GetHelmRelease(context.Background(), client.GetHelmReleaseOpts{
-		ClusterID:   getClusterID(o),
-		ReleaseName: releaseName,
-		Namespace:   namespace,
+func (a ArgoCD) getHelmRelease() (*client.Helm, error) {
+	return a.okctl.services.Helm.GetHelmRelease(context.Background(), client.GetHelmReleaseOpts{
+		ClusterID:   a.okctl.clusterID,
+		ReleaseName: argocd.ReleaseName,
+		Namespace:   argocd.Namespace,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	return release, nil
 }
 
 func getHelmReleaseAppVersion(release *client.Helm) string {
@@ -0,0 +1,56 @@
+package argocd
+
+import (
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/rbac"
+)
+
+// chartPermissions returns the permissions to use the verbs on every kind of resource in the ArgoCD Helm chart, which
+// the okctl services delete and install with the user's credentials
+func chartPermissions(verbs ...string) []rbac.Permission {
+	return withVerbs(verbs, []rbac.Permission{
+		{Group: "apps", Resource: "deployments", Namespace: argoCDNamespace},
+		{Resource: "services", Namespace: argoCDNamespace},
+		{Resource: "serviceaccounts", Namespace: argoCDNamespace},
+		{Resource: "configmaps", Namespace: argoCDNamespace},
+		{Resource: "secrets", Namespace: argoCDNamespace},
+		{Group: "networking.k8s.io", Resource: "ingresses", Namespace: argoCDNamespace},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings"},
+	})
+}
+
+// externalSecretPermissions returns the permissions to use the verbs on the ArgoCD external secrets
+func externalSecretPermissions(verbs ...string) []rbac.Permission {
+	return withVerbs(verbs, []rbac.Permission{
+		{Group: "kubernetes-client.io", Resource: "externalsecrets", Namespace: argoCDNamespace},
+	})
+}
+
+// ingressPermissions are the permissions needed to check whether the ArgoCD ingress exists
+func ingressPermissions() []rbac.Permission {
+	return []rbac.Permission{
+		{Verb: "get", Group: "networking.k8s.io", Resource: "ingresses", Namespace: argoCDNamespace},
+	}
+}
+
+// createArgoCDPermissions are the permissions needed to install ArgoCD, verify it and uninstall it on rollback
+func createArgoCDPermissions() []rbac.Permission {
+	permissions := chartPermissions("create", "delete")
+	permissions = append(permissions, externalSecretPermissions("create")...)
+
+	return append(permissions, ingressPermissions()...)
+}
+
+// withVerbs returns a permission for every combination of the verbs and the resources
+func withVerbs(verbs []string, resources []rbac.Permission) []rbac.Permission {
+	permissions := make([]rbac.Permission, 0, len(verbs)*len(resources))
+
+	for _, verb := range verbs {
+		for _, p := range resources {
+			p.Verb = verb
+			permissions = append(permissions, p)
+		}
+	}
+
+	return permissions
+}
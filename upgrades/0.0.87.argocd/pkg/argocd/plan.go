@@ -0,0 +1,75 @@
+package argocd
+
+import (
+	"fmt"
+
+	merrors "github.com/mishudark/errors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl/pkg/config/constant"
+	"github.com/oslokommune/okctl/pkg/helm/charts/argocd"
+)
+
+var helmReleaseResource = fmt.Sprintf("helm release %s/%s", argocd.Namespace, argocd.ReleaseName) //nolint:gochecknoglobals
+
+func (a ArgoCD) planDeleteHelmRelease() ([]engine.Action, error) {
+	_, err := a.getHelmRelease()
+	if err != nil {
+		if merrors.IsKind(err, merrors.NotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting helm release: %w", err)
+	}
+
+	return []engine.Action{
+		{
+			Description: fmt.Sprintf("delete ArgoCD %s, making ArgoCD unavailable until it is recreated",
+				appVersionBeforeUpgrade),
+			Resource: helmReleaseResource,
+			Risk:     engine.RiskHigh,
+		},
+	}, nil
+}
+
+func (a ArgoCD) planDeleteSecrets() ([]engine.Action, error) {
+	actions := make([]engine.Action, 0)
+
+	for _, name := range []string{argoSecretName, argoPrivateKeyName} {
+		actions = append(actions, engine.Action{
+			Description: "delete external secret",
+			Resource:    fmt.Sprintf("externalsecret %s/%s", constant.DefaultArgoCDNamespace, name),
+			Risk:        engine.RiskHigh,
+		})
+	}
+
+	for _, name := range []string{argoSecretKeyName, argoClientSecretName} {
+		actions = append(actions, engine.Action{
+			Description: "delete secret parameter",
+			Resource:    fmt.Sprintf("ssm parameter %s", name),
+			Risk:        engine.RiskHigh,
+		})
+	}
+
+	return actions, nil
+}
+
+func (a ArgoCD) planWaitForIngress() ([]engine.Action, error) {
+	return []engine.Action{
+		{
+			Description: fmt.Sprintf("wait up to %d seconds for the ingress to be deleted",
+				waitForIngressDeletionTimeoutSeconds),
+			Resource: fmt.Sprintf("ingress %s/%s", argoCDNamespace, argoCDIngressName),
+			Risk:     engine.RiskLow,
+		},
+	}, nil
+}
+
+func (a ArgoCD) planCreateArgoCD() ([]engine.Action, error) {
+	return []engine.Action{
+		{
+			Description: fmt.Sprintf("install ArgoCD %s with new secrets", appVersionAfterUpgrade),
+			Resource:    helmReleaseResource,
+			Risk:        engine.RiskMedium,
+		},
+	}, nil
+}
@@ -0,0 +1,42 @@
+package argocd
+
+import (
+	"fmt"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/okctlstate"
+	"github.com/oslokommune/okctl/pkg/okctl"
+)
+
+// Record returns the okctl state record of this upgrade, which is stored in the same database as the rest of the state.
+// Returns nil if the okctl tools have no okctl environment to store it in, as with NewOkctlTools.
+func (a ArgoCD) Record() engine.Record {
+	if a.okctl.o == nil {
+		return nil
+	}
+
+	return newRecords(a.okctl.o).For(upgradeVersion)
+}
+
+// Records returns okctl's records of which upgrades have been applied to the cluster, without connecting to it
+func Records() (okctlstate.Records, error) {
+	o, err := initializeOkctl()
+	if err != nil {
+		return okctlstate.Records{}, fmt.Errorf("initializing: %w", err)
+	}
+
+	return newRecords(o), nil
+}
+
+// newRecords uses the database initializeState opened, so the records end up in the local state
+func newRecords(o *okctl.Okctl) okctlstate.Records {
+	clusterID := getClusterID(o)
+
+	id := okctlstate.ID{
+		Region:       clusterID.Region,
+		AWSAccountID: clusterID.AWSAccountID,
+		ClusterName:  clusterID.ClusterName,
+	}
+
+	return okctlstate.New(o.DB.From(okctlstate.NodeName), id)
+}
@@ -0,0 +1,57 @@
+package argocd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/remotestate"
+	"github.com/oslokommune/okctl/pkg/api"
+	"github.com/oslokommune/okctl/pkg/client"
+)
+
+// okctlRemoteState adapts okctl's remote state service to remotestate.RemoteState
+type okctlRemoteState struct {
+	service   client.RemoteStateService
+	clusterID api.ID
+}
+
+func (r okctlRemoteState) AcquireLock() error {
+	return r.service.AcquireStateLock(r.clusterID)
+}
+
+func (r okctlRemoteState) ReleaseLock() error {
+	return r.service.ReleaseStateLock(r.clusterID)
+}
+
+func (r okctlRemoteState) Download() (io.Reader, error) {
+	return r.service.Download(r.clusterID)
+}
+
+func (r okctlRemoteState) Upload(state io.Reader) error {
+	return r.service.Upload(r.clusterID, state)
+}
+
+// NewRemoteState returns the cluster's remote state, and the path of the local state it should be downloaded to
+func NewRemoteState() (remotestate.RemoteState, string, error) {
+	o, err := initializeOkctlWithoutState()
+	if err != nil {
+		return nil, "", fmt.Errorf("initializing: %w", err)
+	}
+
+	services, err := o.ClientServices(o.StateHandlers(o.StateNodes()))
+	if err != nil {
+		return nil, "", fmt.Errorf("acquiring client services: %w", err)
+	}
+
+	localStateDBPath, err := getLocalStatePath(o)
+	if err != nil {
+		return nil, "", fmt.Errorf(localStatePathErrFormat, err)
+	}
+
+	remote := okctlRemoteState{
+		service:   services.RemoteState,
+		clusterID: getClusterID(o),
+	}
+
+	return remote, localStateDBPath, nil
+}
@@ -0,0 +1,192 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	stormpkg "github.com/asdine/storm/v3"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/redact"
+	"github.com/oslokommune/okctl/pkg/api"
+	"github.com/oslokommune/okctl/pkg/client"
+	"github.com/oslokommune/okctl/pkg/config/constant"
+	"github.com/oslokommune/okctl/pkg/helm/charts/argocd"
+	typesv1 "github.com/oslokommune/okctl/pkg/kube/externalsecret/api/types/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// restore is an undo action that recreates a deleted resource. It is prepared before the resource is deleted, as it
+// needs data the delete removes, but only added to the undo actions once the delete succeeded. Recreating a resource
+// that still exists would fail the rollback.
+type restore struct {
+	description string
+	fn          func() error
+}
+
+// addTo adds the undo action. A nil restore is a resource that can't be restored, and adds nothing.
+func (r *restore) addTo(undo *engine.Undo) {
+	if r == nil {
+		return
+	}
+
+	undo.Add(r.description, r.fn)
+}
+
+// prepareHelmReleaseRestore returns an undo action that reinstalls the given release. The chart repository isn't
+// available from the cluster, so we get it from the state before the release is deleted.
+func (a ArgoCD) prepareHelmReleaseRestore(release *client.Helm) (*restore, error) {
+	stored, err := a.okctl.state.Helm.GetHelmRelease(argocd.ReleaseName)
+	if err != nil {
+		if errors.Is(err, stormpkg.ErrNotFound) {
+			a.log.Infof("Warning: Helm release '%s' not found in state, it will not be restored on rollback\n",
+				argocd.ReleaseName)
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting helm release from state: %w", err)
+	}
+
+	values, err := yaml.Marshal(release.Release.Config)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling helm release values: %w", err)
+	}
+
+	opts := client.CreateHelmReleaseOpts{
+		ID:             a.okctl.clusterID,
+		RepositoryName: stored.Chart.RepositoryName,
+		RepositoryURL:  stored.Chart.RepositoryURL,
+		ReleaseName:    stored.Chart.ReleaseName,
+		Version:        stored.Chart.Version,
+		Chart:          stored.Chart.Chart,
+		Namespace:      stored.Chart.Namespace,
+		Values:         values,
+	}
+
+	return &restore{
+		description: fmt.Sprintf("restore Helm release %s (%s)", argocd.ReleaseName, appVersionBeforeUpgrade),
+		fn: func() error {
+			_, err := a.okctl.services.Helm.CreateHelmRelease(context.Background(), opts)
+
+			return err
+		},
+	}, nil
+}
+
+func (a ArgoCD) deleteNewHelmRelease() error {
+	return a.okctl.services.Helm.DeleteHelmRelease(context.Background(), client.DeleteHelmReleaseOpts{
+		ID:          a.okctl.clusterID,
+		ReleaseName: argocd.ReleaseName,
+		Namespace:   argocd.Namespace,
+	})
+}
+
+// prepareExternalSecretRestore returns an undo action that recreates the external secret with the given name from
+// the manifest stored in the state
+func (a ArgoCD) prepareExternalSecretRestore(name string) (*restore, error) {
+	manifest, err := a.okctl.state.Manifest.GetKubernetesManifests(name)
+	if err != nil {
+		if errors.Is(err, stormpkg.ErrNotFound) {
+			a.log.Debugf("External secret '%s' not found in state, it will not be restored on rollback\n", name)
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting external secret manifest from state: %w", err)
+	}
+
+	var externalSecret typesv1.ExternalSecret
+
+	err = yaml.Unmarshal(manifest.Content, &externalSecret)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling external secret manifest: %w", err)
+	}
+
+	opts := client.CreateExternalSecretOpts{
+		ID:        a.okctl.clusterID,
+		Name:      manifest.Name,
+		Namespace: manifest.Namespace,
+		Manifest:  toAPIManifest(externalSecret),
+	}
+
+	return &restore{
+		description: fmt.Sprintf("restore external secret %s", name),
+		fn: func() error {
+			// The new ArgoCD release may have created an external secret with the same name
+			err := a.okctl.services.Manifest.DeleteExternalSecret(context.Background(), client.DeleteExternalSecretOpts{
+				ID:   a.okctl.clusterID,
+				Name: name,
+				Secrets: map[string]string{
+					name: constant.DefaultArgoCDNamespace,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("deleting new external secret: %w", err)
+			}
+
+			_, err = a.okctl.services.Manifest.CreateExternalSecret(context.Background(), opts)
+
+			return err
+		},
+	}, nil
+}
+
+func toAPIManifest(externalSecret typesv1.ExternalSecret) api.Manifest {
+	data := make([]api.Data, len(externalSecret.Spec.Data))
+
+	for i, d := range externalSecret.Spec.Data {
+		data[i] = api.Data{
+			Key:      d.Key,
+			Name:     d.Name,
+			Property: d.Property,
+		}
+	}
+
+	manifest := api.Manifest{
+		Name:      externalSecret.Name,
+		Namespace: externalSecret.Namespace,
+		Backend:   externalSecret.Spec.BackendType,
+		Data:      data,
+	}
+
+	if externalSecret.Spec.Template != nil {
+		manifest.Annotations = externalSecret.Spec.Template.Metadata.Annotations
+		manifest.Labels = externalSecret.Spec.Template.Metadata.Labels
+		manifest.Template.StringData = externalSecret.Spec.Template.StringData
+	}
+
+	return manifest
+}
+
+// prepareSecretRestore returns an undo action that recreates the secret parameter with the given name. The state
+// doesn't contain the secret's value, so we read it from SSM before the parameter is deleted.
+func (a ArgoCD) prepareSecretRestore(name string) (*restore, error) {
+	secret, err := a.okctl.state.Parameter.GetSecret(name)
+	if err != nil {
+		if errors.Is(err, stormpkg.ErrNotFound) {
+			a.log.Debugf("Secret '%s' not found in state, it will not be restored on rollback\n", name)
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("getting secret from state: %w", err)
+	}
+
+	value, err := a.okctl.secrets.GetSecretValue(secret.Path)
+	if err != nil {
+		return nil, fmt.Errorf("getting secret value: %w", err)
+	}
+
+	redact.Register(value)
+
+	return &restore{
+		description: fmt.Sprintf("restore secret %s", name),
+		fn: func() error {
+			_, err := a.okctl.services.Parameter.CreateSecret(context.Background(), client.CreateSecretOpts{
+				ID:     a.okctl.clusterID,
+				Name:   name,
+				Secret: value,
+			})
+
+			return err
+		},
+	}, nil
+}
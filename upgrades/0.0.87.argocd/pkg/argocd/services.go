@@ -0,0 +1,108 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/oslokommune/okctl/pkg/client"
+)
+
+// The interfaces below contain the parts of okctl's services and state handlers the upgrade uses. okctl's
+// implementations satisfy them, and tests use fakes.
+
+// HelmService installs and removes Helm releases
+type HelmService interface {
+	CreateHelmRelease(ctx context.Context, opts client.CreateHelmReleaseOpts) (*client.Helm, error)
+	DeleteHelmRelease(ctx context.Context, opts client.DeleteHelmReleaseOpts) error
+	GetHelmRelease(ctx context.Context, opts client.GetHelmReleaseOpts) (*client.Helm, error)
+}
+
+// ManifestService creates and deletes external secrets
+type ManifestService interface {
+	CreateExternalSecret(ctx context.Context, opts client.CreateExternalSecretOpts) (*client.KubernetesManifest, error)
+	DeleteExternalSecret(ctx context.Context, opts client.DeleteExternalSecretOpts) error
+}
+
+// ParameterService creates and deletes secrets stored as SSM parameters
+type ParameterService interface {
+	CreateSecret(ctx context.Context, opts client.CreateSecretOpts) (*client.SecretParameter, error)
+	DeleteSecret(ctx context.Context, opts client.DeleteSecretOpts) error
+}
+
+// GithubService sets up the IAC repository
+type GithubService interface {
+	CreateGithubRepository(ctx context.Context, opts client.CreateGithubRepositoryOpts) (*client.GithubRepository, error)
+}
+
+// ArgoCDService installs ArgoCD
+type ArgoCDService interface {
+	CreateArgoCD(ctx context.Context, opts client.CreateArgoCDOpts) (*client.ArgoCD, error)
+}
+
+// HelmState reads Helm releases from the state
+type HelmState interface {
+	GetHelmRelease(releaseName string) (*client.Helm, error)
+}
+
+// ManifestState reads Kubernetes manifests from the state
+type ManifestState interface {
+	GetKubernetesManifests(name string) (*client.KubernetesManifest, error)
+}
+
+// ParameterState reads secret parameters from the state
+type ParameterState interface {
+	GetSecret(name string) (*client.SecretParameter, error)
+}
+
+// DomainState reads hosted zones from the state
+type DomainState interface {
+	GetPrimaryHostedZone() (*client.HostedZone, error)
+}
+
+// IdentityManagerState reads identity pools from the state
+type IdentityManagerState interface {
+	GetIdentityPool(stackName string) (*client.IdentityPool, error)
+}
+
+// SecretReader reads the value of a secret parameter
+type SecretReader interface {
+	GetSecretValue(path string) (string, error)
+}
+
+// Services are the okctl services the upgrade uses
+type Services struct {
+	Helm      HelmService
+	Manifest  ManifestService
+	Parameter ParameterService
+	Github    GithubService
+	ArgoCD    ArgoCDService
+}
+
+// State is the okctl state the upgrade uses
+type State struct {
+	Helm            HelmState
+	Manifest        ManifestState
+	Parameter       ParameterState
+	Domain          DomainState
+	IdentityManager IdentityManagerState
+}
+
+// ssmSecretReader reads secret values from SSM, as the state only contains the path of a secret
+type ssmSecretReader struct {
+	ssm ssmiface.SSMAPI
+}
+
+func (r ssmSecretReader) GetSecretValue(path string) (string, error) {
+	parameter, err := r.ssm.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(path),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("getting parameter: %w", err)
+	}
+
+	return aws.StringValue(parameter.Parameter.Value), nil
+}
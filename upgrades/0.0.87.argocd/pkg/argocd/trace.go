@@ -0,0 +1,152 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
+	"github.com/oslokommune/okctl/pkg/client"
+)
+
+// traceServices returns services that record every call in the trace. Only what each call targets is recorded, as
+// the arguments may contain secrets.
+func traceServices(s Services, recorder *trace.Recorder) Services {
+	if recorder == nil {
+		return s
+	}
+
+	return Services{
+		Helm:      tracedHelmService{next: s.Helm, trace: recorder},
+		Manifest:  tracedManifestService{next: s.Manifest, trace: recorder},
+		Parameter: tracedParameterService{next: s.Parameter, trace: recorder},
+		Github:    tracedGithubService{next: s.Github, trace: recorder},
+		ArgoCD:    tracedArgoCDService{next: s.ArgoCD, trace: recorder},
+	}
+}
+
+type tracedHelmService struct {
+	next  HelmService
+	trace *trace.Recorder
+}
+
+func (t tracedHelmService) CreateHelmRelease(
+	ctx context.Context,
+	opts client.CreateHelmReleaseOpts,
+) (result *client.Helm, err error) {
+	err = t.trace.Call("Helm.CreateHelmRelease", namespaced(opts.Namespace, opts.ReleaseName), func() error {
+		result, err = t.next.CreateHelmRelease(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+func (t tracedHelmService) DeleteHelmRelease(ctx context.Context, opts client.DeleteHelmReleaseOpts) error {
+	return t.trace.Call("Helm.DeleteHelmRelease", namespaced(opts.Namespace, opts.ReleaseName), func() error {
+		return t.next.DeleteHelmRelease(ctx, opts)
+	})
+}
+
+func (t tracedHelmService) GetHelmRelease(
+	ctx context.Context,
+	opts client.GetHelmReleaseOpts,
+) (result *client.Helm, err error) {
+	err = t.trace.Call("Helm.GetHelmRelease", namespaced(opts.Namespace, opts.ReleaseName), func() error {
+		result, err = t.next.GetHelmRelease(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+type tracedManifestService struct {
+	next  ManifestService
+	trace *trace.Recorder
+}
+
+func (t tracedManifestService) CreateExternalSecret(
+	ctx context.Context,
+	opts client.CreateExternalSecretOpts,
+) (result *client.KubernetesManifest, err error) {
+	err = t.trace.Call("Manifest.CreateExternalSecret", namespaced(opts.Namespace, opts.Name), func() error {
+		result, err = t.next.CreateExternalSecret(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+func (t tracedManifestService) DeleteExternalSecret(ctx context.Context, opts client.DeleteExternalSecretOpts) error {
+	return t.trace.Call("Manifest.DeleteExternalSecret", opts.Name, func() error {
+		return t.next.DeleteExternalSecret(ctx, opts)
+	})
+}
+
+type tracedParameterService struct {
+	next  ParameterService
+	trace *trace.Recorder
+}
+
+func (t tracedParameterService) CreateSecret(
+	ctx context.Context,
+	opts client.CreateSecretOpts,
+) (result *client.SecretParameter, err error) {
+	err = t.trace.Call("Parameter.CreateSecret", opts.Name, func() error {
+		result, err = t.next.CreateSecret(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+func (t tracedParameterService) DeleteSecret(ctx context.Context, opts client.DeleteSecretOpts) error {
+	return t.trace.Call("Parameter.DeleteSecret", opts.Name, func() error {
+		return t.next.DeleteSecret(ctx, opts)
+	})
+}
+
+type tracedGithubService struct {
+	next  GithubService
+	trace *trace.Recorder
+}
+
+func (t tracedGithubService) CreateGithubRepository(
+	ctx context.Context,
+	opts client.CreateGithubRepositoryOpts,
+) (result *client.GithubRepository, err error) {
+	target := fmt.Sprintf("%s/%s", opts.Organization, opts.Name)
+
+	err = t.trace.Call("Github.CreateGithubRepository", target, func() error {
+		result, err = t.next.CreateGithubRepository(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+type tracedArgoCDService struct {
+	next  ArgoCDService
+	trace *trace.Recorder
+}
+
+func (t tracedArgoCDService) CreateArgoCD(
+	ctx context.Context,
+	opts client.CreateArgoCDOpts,
+) (result *client.ArgoCD, err error) {
+	err = t.trace.Call("ArgoCD.CreateArgoCD", opts.Domain, func() error {
+		result, err = t.next.CreateArgoCD(ctx, opts)
+
+		return err
+	})
+
+	return result, err
+}
+
+func namespaced(namespace, name string) string {
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
@@ -0,0 +1,83 @@
+package argocd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd/argocdtest"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/redact"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
+)
+
+func TestTraceServices(t *testing.T) {
+	argoCD, _ := newTestArgoCD(t, appVersionBeforeUpgrade)
+
+	out := &bytes.Buffer{}
+	argoCD.okctl.services = traceServices(argoCD.okctl.services, trace.New(out))
+
+	e := engine.New(logger.New(logger.Error), engine.Opts{Confirm: true, AcceptDataLoss: true})
+
+	_, err := e.Run(argoCD.Upgrade())
+	if err != nil {
+		t.Fatalf("running upgrade: %s", err)
+	}
+
+	var calls []string
+
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var entry trace.Entry
+
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			t.Fatalf("parsing trace: %s", err)
+		}
+
+		if entry.Kind != trace.KindOkctl {
+			t.Errorf("expected kind %s, got %s", trace.KindOkctl, entry.Kind)
+		}
+
+		calls = append(calls, entry.Method+" "+entry.Target)
+	}
+
+	expectCalls := []string{
+		"Helm.GetHelmRelease argocd/argocd",
+		"Helm.GetHelmRelease argocd/argocd",
+		"Helm.DeleteHelmRelease argocd/argocd",
+		"Manifest.DeleteExternalSecret argocd-secret",
+		"Manifest.DeleteExternalSecret argocd-privatekey",
+		"Parameter.DeleteSecret argocd/secret_key",
+		"Parameter.DeleteSecret argocd/client_secret",
+		"Github.CreateGithubRepository oslokommune/test-iac",
+		"ArgoCD.CreateArgoCD test.example.com",
+		"Helm.GetHelmRelease argocd/argocd",
+	}
+
+	if !reflect.DeepEqual(calls, expectCalls) {
+		t.Errorf("expected calls\n%v\ngot\n%v", expectCalls, calls)
+	}
+}
+
+func TestSecretValuesAreRedacted(t *testing.T) {
+	argoCD, _ := newTestArgoCD(t, appVersionBeforeUpgrade)
+
+	e := engine.New(logger.New(logger.Error), engine.Opts{Confirm: true, AcceptDataLoss: true})
+
+	_, err := e.Run(argoCD.Upgrade())
+	if err != nil {
+		t.Fatalf("running upgrade: %s", err)
+	}
+
+	for _, name := range []string{argoSecretKeyName, argoClientSecretName} {
+		value, _ := argocdtest.SecretReader{}.GetSecretValue("/okctl/test/" + name)
+
+		if got := redact.String("read " + value); got != "read "+redact.Placeholder {
+			t.Errorf("expected the value of %s to be redacted, got %s", name, got)
+		}
+	}
+}
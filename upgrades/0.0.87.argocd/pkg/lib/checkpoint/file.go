@@ -0,0 +1,94 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File stores the names of completed steps as JSON in a file, so an interrupted upgrade can be resumed
+type File struct {
+	path string
+}
+
+type content struct {
+	CompletedSteps []string  `json:"completedSteps"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// Load returns the steps completed by a previous run. If there is no checkpoint file, no steps are returned.
+func (f File) Load() ([]string, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(f.path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("reading checkpoint file: %w", err)
+	}
+
+	var c content
+
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling checkpoint file %s: %w", f.path, err)
+	}
+
+	return c.CompletedSteps, nil
+}
+
+// Save stores the given completed steps. Saving no steps removes the checkpoint file.
+func (f File) Save(completedSteps []string) error {
+	if len(completedSteps) == 0 {
+		err := os.Remove(f.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing checkpoint file: %w", err)
+		}
+
+		return nil
+	}
+
+	raw, err := json.MarshalIndent(content{
+		CompletedSteps: completedSteps,
+		UpdatedAt:      time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling checkpoint: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(f.path), 0o700)
+	if err != nil {
+		return fmt.Errorf("creating checkpoint directory: %w", err)
+	}
+
+	// Write to a temporary file first, so an interruption doesn't leave a half written checkpoint behind
+	tmpPath := f.path + ".tmp"
+
+	err = ioutil.WriteFile(tmpPath, raw, 0o600)
+	if err != nil {
+		return fmt.Errorf("writing checkpoint file: %w", err)
+	}
+
+	err = os.Rename(tmpPath, f.path)
+	if err != nil {
+		return fmt.Errorf("replacing checkpoint file: %w", err)
+	}
+
+	return nil
+}
+
+// Path returns the path of the checkpoint file
+func (f File) Path() string {
+	return f.path
+}
+
+// NewFile returns a checkpoint store using the file at the given path
+func NewFile(path string) File {
+	return File{
+		path: path,
+	}
+}
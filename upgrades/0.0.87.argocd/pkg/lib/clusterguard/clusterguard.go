@@ -0,0 +1,148 @@
+// Package clusterguard refuses to run an upgrade against another cluster than the one in the cluster declaration, for
+// instance because KUBECONFIG or the AWS credentials were left pointing at another cluster
+package clusterguard
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ErrWrongCluster is returned when the environment points at another cluster than the cluster declaration
+var ErrWrongCluster = errors.New("the environment doesn't point at the cluster in the cluster declaration")
+
+// eksDomain is the domain of EKS API server endpoints, which are on the form <id>.<zone>.<region>.eks.amazonaws.com
+const eksDomain = ".eks.amazonaws.com"
+
+// Cluster is the cluster from the cluster declaration
+type Cluster struct {
+	Name      string
+	Region    string
+	AccountID string
+}
+
+// Environment is the cluster KUBECONFIG and the AWS credentials point at
+type Environment struct {
+	// Context is the kubeconfig's current context
+	Context string
+	// Cluster is the name of the current context's cluster in the kubeconfig
+	Cluster string
+	// Server is the API server endpoint of that cluster
+	Server string
+	// AccountID is the AWS account the credentials belong to
+	AccountID string
+}
+
+// Check returns ErrWrongCluster, listing every difference, if the environment doesn't point at the declared cluster.
+// It expects the kubeconfig okctl creates, where the cluster is named <name>.<region>.eksctl.io and the context
+// <user>@<name>.<region>.eksctl.io.
+func Check(declared Cluster, env Environment) error {
+	var diff []string
+
+	expectedCluster := fmt.Sprintf("%s.%s.eksctl.io", declared.Name, declared.Region)
+
+	if !strings.HasSuffix(env.Context, "@"+expectedCluster) {
+		diff = append(diff, fmt.Sprintf("kubeconfig context: expected <user>@%s, got %s", expectedCluster, env.Context))
+	}
+
+	if env.Cluster != expectedCluster {
+		diff = append(diff, fmt.Sprintf("kubeconfig cluster: expected %s, got %s", expectedCluster, env.Cluster))
+	}
+
+	if region := eksRegion(env.Server); region != declared.Region {
+		diff = append(diff, fmt.Sprintf("kubeconfig server: expected an EKS endpoint in %s, got %s", declared.Region,
+			env.Server))
+	}
+
+	if env.AccountID != declared.AccountID {
+		diff = append(diff, fmt.Sprintf("AWS account: expected %s, got %s", declared.AccountID, env.AccountID))
+	}
+
+	if len(diff) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w:\n  %s", ErrWrongCluster, strings.Join(diff, "\n  "))
+}
+
+// eksRegion returns the region of an EKS API server endpoint, or an empty string if it isn't one
+func eksRegion(server string) string {
+	u, err := url.Parse(server)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Hostname()
+	if !strings.HasSuffix(host, eksDomain) {
+		return ""
+	}
+
+	labels := strings.Split(strings.TrimSuffix(host, eksDomain), ".")
+
+	return labels[len(labels)-1]
+}
+
+// FromEnv reads the kubeconfig KUBECONFIG points to, and asks AWS which account the credentials belong to
+func FromEnv() (Environment, error) {
+	kubeConfigPath := os.Getenv("KUBECONFIG")
+	if kubeConfigPath == "" {
+		return Environment{}, errors.New("missing required KUBECONFIG environment variable")
+	}
+
+	env, err := ReadKubeconfig(kubeConfigPath)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	env.AccountID, err = AWSAccount()
+	if err != nil {
+		return Environment{}, err
+	}
+
+	return env, nil
+}
+
+// ReadKubeconfig returns the current context of the kubeconfig, and its cluster and server. AccountID is not set.
+func ReadKubeconfig(path string) (Environment, error) {
+	cfg, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return Environment{}, fmt.Errorf("reading kubeconfig: %w", err)
+	}
+
+	env := Environment{Context: cfg.CurrentContext}
+
+	context, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		return env, nil
+	}
+
+	env.Cluster = context.Cluster
+
+	if cluster, ok := cfg.Clusters[context.Cluster]; ok {
+		env.Server = cluster.Server
+	}
+
+	return env, nil
+}
+
+// AWSAccount returns the AWS account the credentials in the environment belong to
+func AWSAccount() (string, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return "", fmt.Errorf("creating AWS session: %w", err)
+	}
+
+	identity, err := sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("getting AWS account of the credentials in the environment: %w", err)
+	}
+
+	return aws.StringValue(identity.Account), nil
+}
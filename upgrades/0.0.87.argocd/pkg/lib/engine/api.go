@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/events"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/prompt"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/rbac"
+)
+
+//nolint:gochecknoglobals
+var (
+	// QuestionContinue is asked after the preflight checks, before any step is applied
+	QuestionContinue = prompt.Question{ID: "continue", Message: "Do you want to continue?"}
+
+	// QuestionClusterName is asked after QuestionContinue if any pending step is destructive. The answer must be the
+	// name of the cluster.
+	QuestionClusterName = prompt.Question{ID: "cluster-name", Message: "Type the name of the cluster to continue:"}
+)
+
+// Upgrade is the set of steps making up an upgrade
+type Upgrade struct {
+	// Name is the human readable name of what is being upgraded, for instance "ArgoCD"
+	Name string
+
+	// Preflight runs before any step preflight. Return commonerrors.ErrNothingToDo to skip the whole upgrade. Optional.
+	Preflight func() error
+
+	// Preconditions returns facts about the cluster that a saved plan depends on, for instance the installed version.
+	// Applying a saved plan fails if any of them have changed since the plan was made. Optional.
+	Preconditions func() (map[string]string, error)
+
+	// Steps are run in order
+	Steps []Step
+}
+
+// Step is a single unit of work in an upgrade
+type Step struct {
+	// Name identifies the step in output and in the summary
+	Name string
+
+	// Preflight checks if the step should be applied. Return commonerrors.ErrNothingToDo to skip the step. Optional.
+	Preflight func() error
+
+	// Plan describes the changes Apply would make, without making them. It is used for machine-readable output in
+	// dry-run mode. Optional.
+	Plan func() ([]Action, error)
+
+	// Apply makes the actual changes. It is never called in dry-run mode. Changes that can be reverted should be
+	// recorded with undo.Add, so they can be rolled back if this or a later step fails.
+	Apply func(undo *Undo) error
+
+	// Verify checks that Apply had the intended effect. It is never called in dry-run mode. Optional.
+	Verify func() error
+
+	// Rollback reverts the changes made by Apply. It is called if this or a later step fails, after any actions the
+	// step recorded with undo.Add. Optional.
+	Rollback func() error
+
+	// Destructive means Apply deletes data that can't be restored. The user must type the cluster name before such
+	// steps run, see Opts.ClusterName.
+	Destructive bool
+
+	// Permissions are the Kubernetes requests Apply, Verify and Rollback make. The user's access to all of them is
+	// checked before any step runs, see Opts.Access. Optional.
+	Permissions []rbac.Permission
+}
+
+// Status describes what happened to a step
+type Status string
+
+const (
+	// StatusPending means the step has not been run (yet)
+	StatusPending Status = "pending"
+	// StatusSkipped means the step's preflight reported that there was nothing to do
+	StatusSkipped Status = "skipped"
+	// StatusSimulated means the step would have been applied if not running in dry-run mode
+	StatusSimulated Status = "simulated"
+	// StatusApplied means the step was applied and verified
+	StatusApplied Status = "applied"
+	// StatusAppliedPreviously means the step was applied by a previous run that didn't complete
+	StatusAppliedPreviously Status = "applied in previous run"
+	// StatusFailed means applying or verifying the step failed
+	StatusFailed Status = "failed"
+	// StatusRolledBack means the step was applied, but its changes have been rolled back because of a failure
+	StatusRolledBack Status = "rolled back"
+)
+
+// StepResult is the outcome of a single step
+type StepResult struct {
+	Name   string
+	Status Status
+}
+
+// Summary contains the outcome of every step in an upgrade
+type Summary struct {
+	Steps []StepResult
+}
+
+// Checkpointer persists which steps have been completed, so that an interrupted upgrade can be resumed from the
+// first step that did not complete
+type Checkpointer interface {
+	Load() ([]string, error)
+	Save(completedSteps []string) error
+}
+
+// Record keeps track of whether the upgrade has been applied to the cluster, so that it isn't run again
+type Record interface {
+	IsApplied() (bool, error)
+	MarkApplied() error
+}
+
+// AccessChecker checks that the user is allowed to make the requests the steps need. rbac.Checker implements it.
+type AccessChecker interface {
+	Check(permissions []rbac.Permission) error
+}
+
+// Opts contains the flags the engine needs to know about
+type Opts struct {
+	DryRun     bool
+	Confirm    bool
+	NoRollback bool
+
+	// Checkpoints is used to resume interrupted upgrades. Optional.
+	Checkpoints Checkpointer
+
+	// Record is checked before running any preflight checks, and updated after a successful upgrade. Optional.
+	Record Record
+
+	// Prompter asks the user questions. Defaults to a prompt in the terminal.
+	Prompter prompt.Prompter
+
+	// Access checks the permissions of the pending steps after the preflight checks. Optional.
+	Access AccessChecker
+
+	// ClusterName is the name from the cluster declaration, which the user must type to run destructive steps.
+	// Required if any step is destructive, except in dry-run mode.
+	ClusterName string
+
+	// AcceptDataLoss runs destructive steps without asking for the cluster name. Without it, destructive steps
+	// can't run with Confirm or Apply, as nobody is asked.
+	AcceptDataLoss bool
+
+	// Stop makes the engine stop before the next step, and roll back the steps applied so far, when it is closed. The
+	// current step always finishes. Optional.
+	Stop <-chan struct{}
+
+	// Events receives progress events, see pkg/lib/events. Optional.
+	Events *events.Emitter
+
+	// ReportSkipped makes Run and Apply return commonerrors.ErrNothingToDo if there was nothing to do, so that the
+	// upgrade can exit with exitcode.Skipped
+	ReportSkipped bool
+}
+
+// Engine runs upgrades
+type Engine struct {
+	log            logger.Logger
+	dryRun         bool
+	confirm        bool
+	noRollback     bool
+	checkpoints    Checkpointer
+	record         Record
+	prompter       prompt.Prompter
+	access         AccessChecker
+	clusterName    string
+	acceptDataLoss bool
+	stop           <-chan struct{}
+	events         *events.Emitter
+	reportSkipped  bool
+
+	// upgrade is the name of the upgrade being run, for events
+	upgrade string
+}
+
+// New returns an engine that runs upgrades according to the given options
+func New(log logger.Logger, opts Opts) Engine {
+	prompter := opts.Prompter
+	if prompter == nil {
+		prompter = prompt.Survey{}
+	}
+
+	return Engine{
+		log:            log,
+		dryRun:         opts.DryRun,
+		confirm:        opts.Confirm,
+		noRollback:     opts.NoRollback,
+		checkpoints:    opts.Checkpoints,
+		record:         opts.Record,
+		prompter:       prompter,
+		access:         opts.Access,
+		clusterName:    opts.ClusterName,
+		acceptDataLoss: opts.AcceptDataLoss,
+		stop:           opts.Stop,
+		events:         opts.Events,
+		reportSkipped:  opts.ReportSkipped,
+	}
+}
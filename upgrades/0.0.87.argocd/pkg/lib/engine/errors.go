@@ -0,0 +1,42 @@
+package engine
+
+import "errors"
+
+// ErrDataLossNotAccepted is returned when destructive steps would run without asking the user, and
+// Opts.AcceptDataLoss isn't set
+var ErrDataLossNotAccepted = errors.New("data loss not accepted")
+
+// ErrStopped is returned, wrapped in an ApplyError, when the upgrade is stopped through Opts.Stop
+var ErrStopped = errors.New("upgrade stopped")
+
+// PreflightError is returned when a preflight check fails, or the cluster doesn't match a saved plan. Nothing has
+// been changed.
+type PreflightError struct {
+	Err error
+}
+
+func (e PreflightError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PreflightError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyError is returned when applying or verifying a step fails
+type ApplyError struct {
+	Err error
+
+	// RolledBack is true if every recorded undo action ran successfully. It is false if rollback was disabled or
+	// failed, or if steps applied by a previous run couldn't be rolled back, in which case the cluster may be left
+	// half upgraded.
+	RolledBack bool
+}
+
+func (e ApplyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ApplyError) Unwrap() error {
+	return e.Err
+}
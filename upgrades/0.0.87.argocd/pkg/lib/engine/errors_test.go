@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/commonerrors"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+)
+
+func TestErrors(t *testing.T) {
+	boom := errors.New("boom")
+
+	testCases := []struct {
+		name   string
+		opts   Opts
+		step   Step
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name: "Should return a preflight error when a preflight check fails",
+			opts: Opts{Confirm: true},
+			step: Step{Name: "first", Preflight: func() error { return boom }, Apply: func(_ *Undo) error { return nil }},
+			expect: func(t *testing.T, err error) {
+				var preflightErr PreflightError
+				if !errors.As(err, &preflightErr) || !errors.Is(err, boom) {
+					t.Errorf("expected a preflight error wrapping %v, got %v", boom, err)
+				}
+			},
+		},
+		{
+			name: "Should return a rolled back apply error when a step fails",
+			opts: Opts{Confirm: true},
+			step: Step{Name: "first", Apply: func(_ *Undo) error { return boom }},
+			expect: func(t *testing.T, err error) {
+				var applyErr ApplyError
+				if !errors.As(err, &applyErr) || !applyErr.RolledBack {
+					t.Errorf("expected a rolled back apply error, got %v", err)
+				}
+			},
+		},
+		{
+			name: "Should return an apply error that isn't rolled back when rollback is disabled",
+			opts: Opts{Confirm: true, NoRollback: true},
+			step: Step{Name: "first", Apply: func(_ *Undo) error { return boom }},
+			expect: func(t *testing.T, err error) {
+				var applyErr ApplyError
+				if !errors.As(err, &applyErr) || applyErr.RolledBack {
+					t.Errorf("expected an apply error that isn't rolled back, got %v", err)
+				}
+			},
+		},
+		{
+			name: "Should return an apply error that isn't rolled back when rollback fails",
+			opts: Opts{Confirm: true},
+			step: Step{
+				Name:     "first",
+				Apply:    func(_ *Undo) error { return boom },
+				Rollback: func() error { return errors.New("rollback failed") },
+			},
+			expect: func(t *testing.T, err error) {
+				var applyErr ApplyError
+				if !errors.As(err, &applyErr) || applyErr.RolledBack {
+					t.Errorf("expected an apply error that isn't rolled back, got %v", err)
+				}
+			},
+		},
+		{
+			name: "Should report that there was nothing to do when asked to",
+			opts: Opts{Confirm: true, ReportSkipped: true},
+			step: Step{
+				Name:      "first",
+				Preflight: func() error { return commonerrors.ErrNothingToDo },
+				Apply:     func(_ *Undo) error { return nil },
+			},
+			expect: func(t *testing.T, err error) {
+				if !errors.Is(err, commonerrors.ErrNothingToDo) {
+					t.Errorf("expected %v, got %v", commonerrors.ErrNothingToDo, err)
+				}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			e := New(logger.New(logger.Error), tc.opts)
+
+			_, err := e.Run(Upgrade{Name: "test", Steps: []Step{tc.step}})
+
+			tc.expect(t, err)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Package events defines the progress events an upgrade emits with --events, so that okctl can render progress and
+// aggregate results across upgrades instead of scraping log output.
+//
+// Events are written as JSON objects, one per line. Consumers should check Version, and ignore event types and
+// fields they don't know about, as new ones may be added without changing the version.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/redact"
+)
+
+// Version is the version of the protocol. It changes only when existing fields change meaning or are removed.
+const Version = 1
+
+// Type is the kind of event
+type Type string
+
+const (
+	// TypeStepStarted is emitted before a step is applied or simulated
+	TypeStepStarted Type = "step_started"
+	// TypeStepFinished is emitted when a step has been applied, simulated, skipped or has failed. Status is set.
+	TypeStepFinished Type = "step_finished"
+	// TypePromptNeeded is emitted before the upgrade waits for an answer from the user. Message and Question are set.
+	TypePromptNeeded Type = "prompt_needed"
+	// TypeWarning is emitted for things the user should know about before continuing. Message is the warning.
+	TypeWarning Type = "warning"
+	// TypeNothingToDo is emitted when the preflight checks find that the cluster is already up to date
+	TypeNothingToDo Type = "nothing_to_do"
+	// TypeCompleted is the last event of a run. Result and Steps are set, and Error if the upgrade failed.
+	TypeCompleted Type = "completed"
+)
+
+// Result is the outcome of a run
+type Result string
+
+const (
+	// ResultApplied means at least one step was applied
+	ResultApplied Result = "applied"
+	// ResultSimulated means the run was a dry-run, and at least one step would have been applied
+	ResultSimulated Result = "simulated"
+	// ResultSkipped means there was nothing to do
+	ResultSkipped Result = "skipped"
+	// ResultAborted means the user answered no when asked to continue
+	ResultAborted Result = "aborted"
+	// ResultFailed means the upgrade failed
+	ResultFailed Result = "failed"
+)
+
+// Event is a single progress event
+type Event struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Type    Type      `json:"type"`
+
+	// Upgrade is the name of the upgrade, for instance "ArgoCD"
+	Upgrade string `json:"upgrade"`
+
+	// Step is the name of the step the event is about, if any
+	Step string `json:"step,omitempty"`
+	// StepNumber is the position of the step, starting at 1
+	StepNumber int `json:"stepNumber,omitempty"`
+	// StepCount is the number of steps in the upgrade
+	StepCount int `json:"stepCount,omitempty"`
+	// Status is the status of the step when it finished, as in the summary
+	Status string `json:"status,omitempty"`
+
+	// Message is the question of a prompt, or the text of a warning
+	Message string `json:"message,omitempty"`
+	// Question is the ID of the question of a prompt, which it can be answered by in an --answers file
+	Question string `json:"question,omitempty"`
+
+	// Result is the outcome of the run
+	Result Result `json:"result,omitempty"`
+	// Steps is the final status of every step
+	Steps []StepResult `json:"steps,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// StepResult is the final status of a step
+type StepResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Emitter writes events. A nil emitter emits nothing, so callers don't need to check whether events are enabled.
+type Emitter struct {
+	lock sync.Mutex
+	out  io.Writer
+	now  func() time.Time
+}
+
+// New returns an emitter that writes to the given writer
+func New(out io.Writer) *Emitter {
+	return &Emitter{out: out, now: time.Now}
+}
+
+// Open returns an emitter for the value of the --events flag: "jsonl" for stdout, or "fd:<n>" for an open file
+// descriptor, like one okctl passes to the subprocess. It returns nil if the value is empty.
+func Open(target string) (*Emitter, error) {
+	switch {
+	case target == "":
+		return nil, nil
+	case target == "jsonl":
+		return New(os.Stdout), nil
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("parsing file descriptor in --events=%s", target)
+		}
+
+		f := os.NewFile(uintptr(fd), "events")
+
+		_, err = f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("file descriptor %d is not open: %w", fd, err)
+		}
+
+		return New(f), nil
+	default:
+		return nil, fmt.Errorf("unknown events target '%s', expected jsonl or fd:<n>", target)
+	}
+}
+
+// Emit writes the event, with the version and time set, and sensitive values redacted. Errors are ignored, as a
+// consumer that has gone away must not stop the upgrade.
+func (e *Emitter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+
+	event.Version = Version
+	event.Time = e.now().UTC()
+	event.Message = redact.String(event.Message)
+	event.Error = redact.String(event.Error)
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	_, _ = e.out.Write(append(raw, '\n'))
+}
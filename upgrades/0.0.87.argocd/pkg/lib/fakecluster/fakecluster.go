@@ -0,0 +1,279 @@
+package fakecluster
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/rbac"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// accessReviews is the resource of SelfSubjectAccessReviews, see pkg/lib/rbac
+const accessReviews = "selfsubjectaccessreviews"
+
+// Cluster is a fake Kubernetes cluster for testing upgrades. Pass Clientset to the code under test, and use the
+// helpers to assert the state of the cluster afterwards.
+type Cluster struct {
+	t         *testing.T
+	Clientset *fake.Clientset
+
+	// seeded contains the kinds of the objects the cluster was created with
+	seeded []schema.GroupVersionKind
+
+	// denied are the permissions access reviews deny, see Deny
+	denied map[rbac.Permission]bool
+}
+
+// Deny makes access reviews deny the given permissions. Every other permission is allowed.
+func (c Cluster) Deny(permissions ...rbac.Permission) {
+	for _, p := range permissions {
+		c.denied[p] = true
+	}
+}
+
+// Deployment returns the deployment with the given name, failing the test if it doesn't exist
+func (c Cluster) Deployment(namespace, name string) *appsv1.Deployment {
+	c.t.Helper()
+
+	deployment, err := c.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		c.t.Fatalf("getting deployment %s/%s: %s", namespace, name, err)
+	}
+
+	return deployment
+}
+
+// AssertContainerImage fails the test if the given container in the deployment doesn't have the expected image
+func (c Cluster) AssertContainerImage(namespace, deployment, container, expected string) {
+	c.t.Helper()
+
+	for _, ct := range c.Deployment(namespace, deployment).Spec.Template.Spec.Containers {
+		if ct.Name != container {
+			continue
+		}
+
+		if ct.Image != expected {
+			c.t.Errorf("expected container %s in deployment %s/%s to have image %s, got %s",
+				container, namespace, deployment, expected, ct.Image)
+		}
+
+		return
+	}
+
+	c.t.Errorf("container %s not found in deployment %s/%s", container, namespace, deployment)
+}
+
+// Ingress returns the ingress with the given name, or nil if it doesn't exist
+func (c Cluster) Ingress(namespace, name string) *networkingv1.Ingress {
+	c.t.Helper()
+
+	ingress, err := c.Clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		c.t.Fatalf("getting ingress %s/%s: %s", namespace, name, err)
+	}
+
+	return ingress
+}
+
+// Mutations returns every create, update, patch and delete request made against the cluster
+func (c Cluster) Mutations() []k8stesting.Action {
+	mutations := make([]k8stesting.Action, 0)
+
+	for _, action := range c.Clientset.Actions() {
+		if isAccessReview(action) {
+			continue
+		}
+
+		switch action.GetVerb() {
+		case "create", "update", "patch", "delete", "delete-collection":
+			mutations = append(mutations, action)
+		}
+	}
+
+	return mutations
+}
+
+// Snapshot returns a copy of every object in the cluster, keyed by kind, namespace and name. Only kinds the cluster
+// was seeded with, or that have been created since, are included.
+func (c Cluster) Snapshot() map[string]runtime.Object {
+	c.t.Helper()
+
+	snapshot := make(map[string]runtime.Object)
+
+	for _, gvk := range c.kinds() {
+		gvr, _ := meta.UnsafeGuessKindToResource(gvk)
+
+		list, err := c.Clientset.Tracker().List(gvr, gvk, metav1.NamespaceAll)
+		if err != nil {
+			c.t.Fatalf("listing %s: %s", gvr.Resource, err)
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			c.t.Fatalf("extracting %s: %s", gvr.Resource, err)
+		}
+
+		for _, item := range items {
+			accessor, err := meta.Accessor(item)
+			if err != nil {
+				c.t.Fatalf("accessing %s metadata: %s", gvk.Kind, err)
+			}
+
+			key := fmt.Sprintf("%s %s/%s", gvk.Kind, accessor.GetNamespace(), accessor.GetName())
+			snapshot[key] = item.DeepCopyObject()
+		}
+	}
+
+	return snapshot
+}
+
+// kinds returns the kinds of the seeded objects and of every object created since
+func (c Cluster) kinds() []schema.GroupVersionKind {
+	c.t.Helper()
+
+	seen := make(map[schema.GroupVersionKind]bool)
+	kinds := make([]schema.GroupVersionKind, 0)
+
+	add := func(gvk schema.GroupVersionKind) {
+		if !seen[gvk] {
+			seen[gvk] = true
+			kinds = append(kinds, gvk)
+		}
+	}
+
+	for _, gvk := range c.seeded {
+		add(gvk)
+	}
+
+	for _, action := range c.Clientset.Actions() {
+		create, ok := action.(k8stesting.CreateAction)
+		if !ok || isAccessReview(action) {
+			continue
+		}
+
+		gvks, _, err := scheme.Scheme.ObjectKinds(create.GetObject())
+		if err != nil {
+			c.t.Fatalf("getting kind of created object: %s", err)
+		}
+
+		add(gvks[0])
+	}
+
+	return kinds
+}
+
+// New returns a cluster containing the objects in the given fixture files. A fixture file may contain several
+// objects separated by "---".
+func New(t *testing.T, fixtures ...string) Cluster {
+	t.Helper()
+
+	objects := make([]runtime.Object, 0)
+	seeded := make([]schema.GroupVersionKind, 0)
+
+	for _, fixture := range fixtures {
+		objs, err := readFixture(fixture)
+		if err != nil {
+			t.Fatalf("reading fixture %s: %s", fixture, err)
+		}
+
+		for _, obj := range objs {
+			seeded = append(seeded, obj.GetObjectKind().GroupVersionKind())
+		}
+
+		objects = append(objects, objs...)
+	}
+
+	c := Cluster{
+		t:         t,
+		Clientset: fake.NewSimpleClientset(objects...),
+		seeded:    seeded,
+		denied:    make(map[rbac.Permission]bool),
+	}
+
+	c.Clientset.PrependReactor("create", accessReviews, c.reviewAccess)
+
+	return c
+}
+
+// reviewAccess answers SelfSubjectAccessReviews, which the fake clientset can't store
+func (c Cluster) reviewAccess(action k8stesting.Action) (bool, runtime.Object, error) {
+	review, ok := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+	if !ok || review.Spec.ResourceAttributes == nil {
+		return false, nil, nil
+	}
+
+	attributes := review.Spec.ResourceAttributes
+
+	review = review.DeepCopy()
+	review.Status.Allowed = !c.denied[rbac.Permission{
+		Verb:      attributes.Verb,
+		Group:     attributes.Group,
+		Resource:  attributes.Resource,
+		Namespace: attributes.Namespace,
+	}]
+
+	return true, review, nil
+}
+
+// isAccessReview is true for access reviews, which only ask whether the user is allowed to do something
+func isAccessReview(action k8stesting.Action) bool {
+	return action.GetResource().Resource == accessReviews
+}
+
+func readFixture(path string) ([]runtime.Object, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(raw)))
+	decoder := scheme.Codecs.UniversalDeserializer()
+
+	objects := make([]runtime.Object, 0)
+
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(document)) == 0 {
+			continue
+		}
+
+		obj, _, err := decoder.Decode(document, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
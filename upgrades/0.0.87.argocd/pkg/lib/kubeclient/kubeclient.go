@@ -0,0 +1,96 @@
+// Package kubeclient creates clients for the Kubernetes cluster being upgraded
+package kubeclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Opts contains the flags the client needs to know about
+type Opts struct {
+	// DryRun makes the client refuse every request that would change the cluster. Server-side dry-run requests, made
+	// with metav1.DryRunAll, and access reviews, made by pkg/lib/rbac, are let through.
+	DryRun bool
+
+	// Trace records every request and response. Optional.
+	Trace *trace.Recorder
+
+	// Record saves the requests that reach the cluster to a cassette, for replaying in tests. Optional.
+	Record *replay.Recorder
+}
+
+// Client is a Kubernetes client that keeps track of the requests it refused in dry-run mode
+type Client struct {
+	kubernetes.Interface
+
+	guard *guard
+}
+
+// Blocked returns the requests that were refused in dry-run mode, in the order they were made
+func (c Client) Blocked() []string {
+	if c.guard == nil {
+		return nil
+	}
+
+	return c.guard.list()
+}
+
+// NewFromEnv returns a client for the cluster in the kubeconfig the KUBECONFIG environment variable points to
+func NewFromEnv(opts Opts) (Client, error) {
+	kubeConfigPath := os.Getenv("KUBECONFIG")
+	if kubeConfigPath == "" {
+		return Client{}, errors.New("missing required KUBECONFIG environment variable")
+	}
+
+	return New(kubeConfigPath, opts)
+}
+
+// New returns a client for the cluster in the given kubeconfig
+func New(kubeConfigPath string, opts Opts) (Client, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+	if err != nil {
+		return Client{}, fmt.Errorf("creating rest config: %w", err)
+	}
+
+	return NewForConfig(cfg, opts)
+}
+
+// NewForConfig returns a client for the cluster in the given config
+func NewForConfig(cfg *rest.Config, opts Opts) (Client, error) {
+	var g *guard
+
+	cfg = rest.CopyConfig(cfg)
+
+	// Wrapped first, so that only requests that reach the cluster are recorded
+	if opts.Record != nil {
+		cfg.Wrap(opts.Record.WrapTransport)
+	}
+
+	if opts.DryRun {
+		g = &guard{}
+
+		cfg.Wrap(g.wrap)
+	}
+
+	// Wrapped last, so that requests refused in dry-run mode are traced too
+	if opts.Trace != nil {
+		cfg.Wrap(opts.Trace.WrapTransport)
+	}
+
+	clientSet, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return Client{}, fmt.Errorf("initializing client: %w", err)
+	}
+
+	return Client{
+		Interface: clientSet,
+		guard:     g,
+	}, nil
+}
@@ -1,8 +1,15 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/redact"
 )
 
 type Level int
@@ -18,48 +25,211 @@ const (
 	Error
 )
 
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Error:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// Format is how log lines are written
+type Format string
+
+const (
+	// FormatText writes messages as plain text, for humans
+	FormatText Format = "text"
+
+	// FormatJSON writes one JSON object per line, with a timestamp, the level, the message and any fields added
+	// with With
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatText, FormatJSON:
+		return Format(name), nil
+	default:
+		return "", fmt.Errorf("unknown log format '%s', expected one of: %s, %s", name, FormatText, FormatJSON)
+	}
+}
+
+// Opts configures a logger
+type Opts struct {
+	// Level is the lowest level that is written to Out
+	Level Level
+
+	// Format is the format of the lines written to Out. Defaults to FormatText.
+	Format Format
+
+	// Out receives debug and info messages. Error messages are always written to stderr. Defaults to stdout.
+	Out io.Writer
+
+	// File receives every message as JSON, regardless of Level. Optional.
+	File io.Writer
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
 type Logger struct {
-	level Level
+	level  Level
+	format Format
+	out    io.Writer
+	errOut io.Writer
+	file   io.Writer
+	fields []field
+	now    func() time.Time
 }
 
 func (l Logger) Debug(args ...interface{}) {
-	if l.levelIsEnabled(Debug) {
-		out := make([]interface{}, 0)
-		out = append(out, "[DEBUG]")
-		out = append(out, args...)
+	l.log(Debug, fmt.Sprintln(args...))
+}
+
+func (l Logger) Debugf(format string, args ...interface{}) {
+	l.log(Debug, fmt.Sprintf(format, args...))
+}
+
+func (l Logger) Info(args ...interface{}) {
+	l.log(Info, fmt.Sprintln(args...))
+}
+
+func (l Logger) Infof(format string, args ...interface{}) {
+	l.log(Info, fmt.Sprintf(format, args...))
+}
+
+func (l Logger) Error(args ...interface{}) {
+	l.log(Error, fmt.Sprintln(args...))
+}
+
+func (l Logger) Errorf(format string, args ...interface{}) {
+	l.log(Error, fmt.Sprintf(format, args...))
+}
+
+// With returns a logger that adds the given key-value pairs to every message, for example
+// log.With("step", "delete old release", "resource", "argocd/argocd-server"). A key that is already set is replaced.
+// Fields are only written in the JSON format.
+func (l Logger) With(keyValues ...interface{}) Logger {
+	fields := make([]field, len(l.fields), len(l.fields)+len(keyValues)/2+1)
+	copy(fields, l.fields)
+
+	for i := 0; i < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
 
-		_, _ = fmt.Println(out...)
+		var value interface{}
+		if i+1 < len(keyValues) {
+			value = keyValues[i+1]
+		}
+
+		fields = setField(fields, key, value)
 	}
+
+	l.fields = fields
+
+	return l
 }
 
-func (l Logger) Debugf(format string, args ...interface{}) {
-	if l.levelIsEnabled(Debug) {
-		_, _ = fmt.Printf("[DEBUG] "+format, args...)
+func setField(fields []field, key string, value interface{}) []field {
+	for i := range fields {
+		if fields[i].key == key {
+			fields[i].value = value
+
+			return fields
+		}
 	}
+
+	return append(fields, field{key: key, value: value})
 }
 
-func (l Logger) Info(args ...interface{}) {
-	if l.levelIsEnabled(Info) {
-		_, _ = fmt.Println(args...)
+// log writes the message, with registered sensitive values redacted, see pkg/lib/redact
+func (l Logger) log(level Level, msg string) {
+	now := l.now()
+	msg = redact.String(msg)
+
+	if l.file != nil {
+		_, _ = l.file.Write(l.jsonLine(now, level, msg))
+	}
+
+	if !l.levelIsEnabled(level) {
+		return
+	}
+
+	out := l.out
+	if level == Error {
+		out = l.errOut
+	}
+
+	if l.format == FormatJSON {
+		_, _ = out.Write(l.jsonLine(now, level, msg))
+
+		return
+	}
+
+	if level == Debug {
+		msg = "[DEBUG] " + msg
+	}
+
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
 	}
+
+	_, _ = io.WriteString(out, msg)
 }
 
-func (l Logger) Infof(format string, args ...interface{}) {
-	if l.levelIsEnabled(Info) {
-		_, _ = fmt.Printf(format, args...)
+// jsonLine returns the message as a JSON object on a single line. The timestamp, level and message come first, so
+// the lines are easy to read too.
+func (l Logger) jsonLine(now time.Time, level Level, msg string) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"time":`)
+	writeJSON(&buf, now.UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	writeJSON(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	writeJSON(&buf, strings.TrimSpace(msg))
+
+	for _, f := range l.fields {
+		buf.WriteString(",")
+		writeJSON(&buf, f.key)
+		buf.WriteString(":")
+		writeJSON(&buf, fieldValue(f.value))
 	}
+
+	buf.WriteString("}\n")
+
+	return buf.Bytes()
 }
 
-func (l Logger) Error(args ...interface{}) {
-	if l.levelIsEnabled(Error) {
-		_, _ = fmt.Fprintln(os.Stderr, args...)
+// fieldValue returns a value that marshals to something readable, with sensitive values redacted. Errors and values
+// with a String method would otherwise often end up as {}.
+func fieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redact.String(v)
+	case error:
+		return redact.String(v.Error())
+	case fmt.Stringer:
+		return redact.String(v.String())
+	default:
+		return v
 	}
 }
 
-func (l Logger) Errorf(format string, args ...interface{}) {
-	if l.levelIsEnabled(Error) {
-		_, _ = fmt.Fprintf(os.Stderr, format, args...)
+func writeJSON(buf *bytes.Buffer, value interface{}) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(value))
 	}
+
+	buf.Write(raw)
 }
 
 func (l Logger) levelIsEnabled(level Level) bool {
@@ -67,7 +237,35 @@ func (l Logger) levelIsEnabled(level Level) bool {
 }
 
 func New(level Level) Logger {
-	return Logger{
-		level: level,
+	return NewWithOutput(level, os.Stdout)
+}
+
+// NewWithOutput returns a logger that writes debug and info messages to out instead of stdout
+func NewWithOutput(level Level, out io.Writer) Logger {
+	return NewWithOpts(Opts{
+		Level: level,
+		Out:   out,
+	})
+}
+
+// NewWithOpts returns a logger configured by the given options
+func NewWithOpts(opts Opts) Logger {
+	l := Logger{
+		level:  opts.Level,
+		format: opts.Format,
+		out:    opts.Out,
+		errOut: os.Stderr,
+		file:   opts.File,
+		now:    time.Now,
 	}
+
+	if l.format == "" {
+		l.format = FormatText
+	}
+
+	if l.out == nil {
+		l.out = os.Stdout
+	}
+
+	return l
 }
@@ -0,0 +1,166 @@
+package okctlstate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm/v3/index"
+)
+
+// NodeName is the storm node okctl stores upgrade records in
+const NodeName = "upgrade"
+
+// ErrNotFound is returned when there is no record of an upgrade
+var ErrNotFound = errors.New("no upgrade record found")
+
+// Node is the part of a storm node that is needed to read and write upgrade records. Both storm.Node and okctl's
+// breeze.Client satisfy it.
+type Node interface {
+	All(to interface{}, options ...func(*index.Options)) error
+	Save(data interface{}) error
+	DeleteStruct(data interface{}) error
+}
+
+// Metadata, ID and Upgrade must match how okctl stores upgrade records, see okctl's
+// pkg/client/core/state/storm/upgrade_state.go. Storm uses the type name as bucket name, so Upgrade can't be renamed.
+
+// Metadata contains the fields okctl stores for every record
+type Metadata struct {
+	Identifier int `storm:"id,increment"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time `storm:"index"`
+	Deleted    bool
+}
+
+// ID identifies the cluster a record belongs to
+type ID struct {
+	Region       string
+	AWSAccountID string
+	ClusterName  string
+}
+
+// Upgrade records that the upgrade with the given version has been applied
+type Upgrade struct {
+	Metadata `storm:"inline"`
+
+	ID      ID
+	Version string
+}
+
+// Records reads and writes okctl's records of which upgrades have been applied to a cluster
+type Records struct {
+	node Node
+	id   ID
+}
+
+// List returns all upgrade records
+func (r Records) List() ([]Upgrade, error) {
+	var upgrades []Upgrade
+
+	err := r.node.All(&upgrades)
+	if err != nil {
+		return nil, fmt.Errorf("listing upgrade records: %w", err)
+	}
+
+	return upgrades, nil
+}
+
+// Get returns the record of the upgrade with the given version, and whether it exists
+func (r Records) Get(version string) (Upgrade, bool, error) {
+	upgrades, err := r.List()
+	if err != nil {
+		return Upgrade{}, false, err
+	}
+
+	for _, upgrade := range upgrades {
+		if upgrade.Version == version {
+			return upgrade, true, nil
+		}
+	}
+
+	return Upgrade{}, false, nil
+}
+
+// Save records that the upgrade with the given version has been applied, the same way okctl upgrade does
+func (r Records) Save(version string) error {
+	existing, found, err := r.Get(version)
+	if err != nil {
+		return err
+	}
+
+	upgrade := Upgrade{
+		Metadata: Metadata{
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		ID:      r.id,
+		Version: version,
+	}
+
+	if found {
+		upgrade.Metadata = existing.Metadata
+		upgrade.Metadata.UpdatedAt = time.Now()
+	}
+
+	err = r.node.Save(&upgrade)
+	if err != nil {
+		return fmt.Errorf("saving upgrade record: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the record of the upgrade with the given version, so that okctl upgrade runs it again. It returns
+// ErrNotFound if there is no such record.
+func (r Records) Delete(version string) error {
+	existing, found, err := r.Get(version)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("upgrade %s: %w", version, ErrNotFound)
+	}
+
+	err = r.node.DeleteStruct(&existing)
+	if err != nil {
+		return fmt.Errorf("deleting upgrade record: %w", err)
+	}
+
+	return nil
+}
+
+// For returns the record of a single upgrade, which can be passed to the engine
+func (r Records) For(version string) Record {
+	return Record{
+		records: r,
+		version: version,
+	}
+}
+
+// Record is the okctl state record of a single upgrade
+type Record struct {
+	records Records
+	version string
+}
+
+// IsApplied returns true if okctl has recorded the upgrade as applied
+func (r Record) IsApplied() (bool, error) {
+	_, found, err := r.records.Get(r.version)
+
+	return found, err
+}
+
+// MarkApplied records the upgrade as applied, so okctl upgrade doesn't run it again
+func (r Record) MarkApplied() error {
+	return r.records.Save(r.version)
+}
+
+// New returns upgrade records for the cluster with the given ID, stored in the given node
+func New(node Node, id ID) Records {
+	return Records{
+		node: node,
+		id:   id,
+	}
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Format is how machine-readable output is written
+type Format string
+
+const (
+	// FormatText means human readable log output, and no machine-readable output
+	FormatText Format = "text"
+	// FormatJSON means output is written as indented JSON
+	FormatJSON Format = "json"
+	// FormatYAML means output is written as YAML
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatText, FormatJSON, FormatYAML:
+		return Format(name), nil
+	default:
+		return "", fmt.Errorf("unknown output format '%s', expected one of %s, %s or %s",
+			name, FormatText, FormatJSON, FormatYAML)
+	}
+}
+
+// Write writes v to w in the given format. v is marshalled using its JSON struct tags for both JSON and YAML.
+func Write(w io.Writer, format Format, v interface{}) error {
+	var (
+		raw []byte
+		err error
+	)
+
+	switch format {
+	case FormatJSON:
+		raw, err = json.MarshalIndent(v, "", "  ")
+		raw = append(raw, '\n')
+	case FormatYAML:
+		raw, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("format '%s' is not machine-readable", format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("marshalling output: %w", err)
+	}
+
+	_, err = w.Write(raw)
+	if err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package prompt
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test answers questions in tests, and fails the test if it is asked a question it has no answer for
+type Test struct {
+	t       testing.TB
+	answers map[string]interface{}
+
+	// Asked are the IDs of the questions asked so far, in order
+	Asked []string
+}
+
+// NewTest returns a prompter with the given answers, keyed by question ID. The answers are bools for Confirm and
+// strings for Input, as in an answers file. Pass no answers to fail on any question.
+func NewTest(t testing.TB, answers map[string]interface{}) *Test {
+	return &Test{
+		t:       t,
+		answers: answers,
+	}
+}
+
+// Confirm returns the answer to the question, or fails the test if there is none
+func (p *Test) Confirm(q Question) (bool, error) {
+	p.t.Helper()
+
+	value := p.answer(q)
+
+	answer, ok := value.(bool)
+	if !ok {
+		if value != nil {
+			p.t.Errorf("expected true or false as the answer to question %s, got %v", q.ID, value)
+		}
+
+		return false, fmt.Errorf("%w to question %s", ErrNoAnswer, q.ID)
+	}
+
+	return answer, nil
+}
+
+// Input returns the answer to the question, or fails the test if there is none
+func (p *Test) Input(q Question) (string, error) {
+	p.t.Helper()
+
+	value := p.answer(q)
+
+	answer, ok := value.(string)
+	if !ok {
+		if value != nil {
+			p.t.Errorf("expected text as the answer to question %s, got %v", q.ID, value)
+		}
+
+		return "", fmt.Errorf("%w to question %s", ErrNoAnswer, q.ID)
+	}
+
+	return answer, nil
+}
+
+func (p *Test) answer(q Question) interface{} {
+	p.t.Helper()
+
+	p.Asked = append(p.Asked, q.ID)
+
+	answer, ok := p.answers[q.ID]
+	if !ok {
+		p.t.Errorf("unexpected question %s: %s", q.ID, q.Message)
+	}
+
+	return answer
+}
@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheck(t *testing.T) {
+	getDeployments := Permission{Verb: "get", Group: "apps", Resource: "deployments", Namespace: "monitoring"}
+	deleteIngresses := Permission{Verb: "delete", Group: "networking.k8s.io", Resource: "ingresses", Namespace: "argocd"}
+	listNodes := Permission{Verb: "list", Resource: "nodes"}
+
+	testCases := []struct {
+		name        string
+		permissions []Permission
+		expectError string
+		expectAsked int
+	}{
+		{
+			name:        "Should pass when every permission is allowed",
+			permissions: []Permission{getDeployments, getDeployments},
+			expectAsked: 1,
+		},
+		{
+			name:        "Should list every missing permission",
+			permissions: []Permission{deleteIngresses, getDeployments, listNodes},
+			expectError: "missing Kubernetes permissions:\n" +
+				"  delete ingresses.networking.k8s.io in namespace argocd\n" +
+				"  list nodes",
+			expectAsked: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			asked := 0
+
+			clientSet := fake.NewSimpleClientset()
+			clientSet.PrependReactor("create", "selfsubjectaccessreviews",
+				func(action k8stesting.Action) (bool, runtime.Object, error) {
+					asked++
+
+					review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+					review.Status.Allowed = review.Spec.ResourceAttributes.Verb == "get"
+
+					return true, review, nil
+				})
+
+			err := New(clientSet).Check(tc.permissions)
+
+			if tc.expectError == "" && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if tc.expectError != "" && (!errors.Is(err, ErrMissingPermissions) || err.Error() != tc.expectError) {
+				t.Fatalf("expected error\n%s\ngot\n%v", tc.expectError, err)
+			}
+
+			if asked != tc.expectAsked {
+				t.Errorf("expected %d access reviews, got %d", tc.expectAsked, asked)
+			}
+		})
+	}
+}
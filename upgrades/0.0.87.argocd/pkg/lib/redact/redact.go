@@ -0,0 +1,114 @@
+// Package redact removes sensitive values from log messages and traces. The logger and the trace recorder use it
+// automatically, so upgrades only need to register the values and patterns they know to be sensitive.
+package redact
+
+import (
+	"encoding/base64"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces every redacted value
+const Placeholder = "[redacted]"
+
+// privateKey matches PEM encoded private keys, such as the deploy key ArgoCD uses to read from GitHub
+var privateKey = regexp.MustCompile( //nolint:gochecknoglobals
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+)
+
+// std is the redactor used by the package level functions
+var std = New() //nolint:gochecknoglobals
+
+// Redactor replaces registered values and patterns in text. It is safe for concurrent use.
+type Redactor struct {
+	lock     sync.RWMutex
+	values   map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// New returns a redactor that only knows about private keys
+func New() *Redactor {
+	return &Redactor{
+		values:   map[string]bool{},
+		patterns: []*regexp.Regexp{privateKey},
+	}
+}
+
+// Register marks the value as sensitive, both as is and base64 encoded, as it is in Kubernetes Secrets. Empty values
+// are ignored.
+func (r *Redactor) Register(value string) {
+	if value == "" {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.values[value] = true
+	r.values[base64.StdEncoding.EncodeToString([]byte(value))] = true
+}
+
+// RegisterPattern marks everything matching the pattern as sensitive
+func (r *Redactor) RegisterPattern(pattern *regexp.Regexp) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.patterns = append(r.patterns, pattern)
+}
+
+// String returns s with every registered value and pattern replaced by Placeholder
+func (r *Redactor) String(s string) string {
+	if s == "" {
+		return s
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if len(r.values) > 0 {
+		s = r.replacer().Replace(s)
+	}
+
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllLiteralString(s, Placeholder)
+	}
+
+	return s
+}
+
+// replacer returns a replacer for the registered values. Longer values are replaced first, so that a value
+// containing another value is replaced as a whole.
+func (r *Redactor) replacer() *strings.Replacer {
+	values := make([]string, 0, len(r.values))
+	for value := range r.values {
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, Placeholder)
+	}
+
+	return strings.NewReplacer(pairs...)
+}
+
+// Register marks the value as sensitive in every log message and trace
+func Register(value string) {
+	std.Register(value)
+}
+
+// RegisterPattern marks everything matching the pattern as sensitive in every log message and trace
+func RegisterPattern(pattern *regexp.Regexp) {
+	std.RegisterPattern(pattern)
+}
+
+// String returns s with every registered value and pattern replaced by Placeholder
+func String(s string) string {
+	return std.String(s)
+}
@@ -0,0 +1,56 @@
+package remotestate
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ErrLocked is returned by Memory when acquiring a lock that is already held
+var ErrLocked = errors.New("state is locked")
+
+// Memory is a remote state kept in memory, for use in tests
+type Memory struct {
+	State  []byte
+	Locked bool
+
+	// Uploads is the number of times the state has been uploaded
+	Uploads int
+}
+
+// AcquireLock locks the state, or fails if it is already locked
+func (m *Memory) AcquireLock() error {
+	if m.Locked {
+		return ErrLocked
+	}
+
+	m.Locked = true
+
+	return nil
+}
+
+// ReleaseLock unlocks the state
+func (m *Memory) ReleaseLock() error {
+	m.Locked = false
+
+	return nil
+}
+
+// Download returns the stored state
+func (m *Memory) Download() (io.Reader, error) {
+	return bytes.NewReader(m.State), nil
+}
+
+// Upload replaces the stored state
+func (m *Memory) Upload(state io.Reader) error {
+	raw, err := ioutil.ReadAll(state)
+	if err != nil {
+		return err
+	}
+
+	m.State = raw
+	m.Uploads++
+
+	return nil
+}
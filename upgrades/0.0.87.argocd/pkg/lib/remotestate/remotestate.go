@@ -0,0 +1,243 @@
+package remotestate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+)
+
+// RemoteState is where okctl keeps the cluster's state database between runs
+type RemoteState interface {
+	AcquireLock() error
+	ReleaseLock() error
+	Download() (io.Reader, error)
+	Upload(state io.Reader) error
+}
+
+// ErrInterrupted is matched by InterruptedError
+var ErrInterrupted = errors.New("interrupted")
+
+// InterruptedError is returned when the upgrade is interrupted by a signal, after the upgrade has stopped, the state
+// has been uploaded and the lock released. It wraps the error the upgrade stopped with, if any, so that the exit code
+// still tells whether the changes were rolled back.
+type InterruptedError struct {
+	Signal os.Signal
+	Err    error
+}
+
+func (e InterruptedError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s by %s", ErrInterrupted, e.Signal)
+	}
+
+	return fmt.Sprintf("%s by %s: %s", ErrInterrupted, e.Signal, e.Err)
+}
+
+// Is makes errors.Is(err, ErrInterrupted) true
+func (e InterruptedError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+func (e InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Opts contains the flags the manager needs to know about
+type Opts struct {
+	// ReadOnly means the state is downloaded, but not locked or uploaded. Use it for dry-runs.
+	ReadOnly bool
+}
+
+// Manager downloads the state before running an upgrade, and uploads it afterwards
+type Manager struct {
+	log       logger.Logger
+	remote    RemoteState
+	localPath string
+	readOnly  bool
+
+	// interrupts replaces the SIGINT and SIGTERM signals in tests, and stopInterrupts replaces restoring their default
+	// handling
+	interrupts     <-chan os.Signal
+	stopInterrupts func()
+}
+
+// Run acquires the state lock, downloads the state, runs the upgrade and uploads the state. The lock is always
+// released, also if the upgrade fails or the process is interrupted. The state is uploaded even if the upgrade fails,
+// as the state must match the changes made before the failure.
+//
+// On SIGINT or SIGTERM, stop is closed, and Run waits for the upgrade to return before uploading the state and
+// releasing the lock. Pass stop to engine.Opts.Stop, so that the upgrade stops after the current step and rolls back.
+// A second signal while waiting restores the default handling, so that a third one kills the process. The state is
+// then neither uploaded nor unlocked, and the lock must be released with okctl maintenance state-release-lock.
+func (m Manager) Run(run func(stop <-chan struct{}) error) (err error) {
+	// Listened to before acquiring the lock, so that an interrupt can't kill the process while the lock is held
+	interrupts, stopInterrupts := m.interrupts, m.stopInterrupts
+	if interrupts == nil {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+		stopInterrupts = func() {
+			signal.Stop(signals)
+		}
+
+		defer stopInterrupts()
+
+		interrupts = signals
+	}
+
+	if !m.readOnly {
+		m.log.Info("Acquiring state lock")
+
+		err = m.remote.AcquireLock()
+		if err != nil {
+			return fmt.Errorf("acquiring state lock: %w", err)
+		}
+
+		defer func() {
+			err = m.releaseLock(err)
+		}()
+	}
+
+	err = m.download()
+	if err != nil {
+		return err
+	}
+
+	// Interrupted while acquiring the lock or downloading, so nothing has been changed, and there is nothing to upload
+	select {
+	case sig := <-interrupts:
+		m.log.Infof("Received %s, not starting the upgrade\n", sig)
+
+		return InterruptedError{Signal: sig}
+	default:
+	}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+
+	go func() {
+		done <- run(stop)
+	}()
+
+	select {
+	case err = <-done:
+	case sig := <-interrupts:
+		m.log.Infof("Received %s, stopping the upgrade after the current step\n", sig)
+
+		close(stop)
+
+		err = InterruptedError{Signal: sig, Err: m.wait(done, interrupts, stopInterrupts)}
+	}
+
+	return m.upload(err)
+}
+
+// wait returns the error the interrupted upgrade stops with. On another interrupt, it stops listening for interrupts,
+// so that the next one kills the process.
+func (m Manager) wait(done <-chan error, interrupts <-chan os.Signal, stopInterrupts func()) error {
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-interrupts:
+			m.log.Infof("Received %s again, still waiting for the current step to finish. Interrupt again to force "+
+				"quit, which leaves the state lock held\n", sig)
+
+			if stopInterrupts != nil {
+				stopInterrupts()
+			}
+
+			// Receiving from a nil channel blocks, so only done is waited for
+			interrupts = nil
+		}
+	}
+}
+
+func (m Manager) download() error {
+	m.log.Infof("Downloading state to %s\n", m.localPath)
+
+	state, err := m.remote.Download()
+	if err != nil {
+		return fmt.Errorf("downloading state: %w", err)
+	}
+
+	raw, err := ioutil.ReadAll(state)
+	if err != nil {
+		return fmt.Errorf("reading downloaded state: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(m.localPath), 0o700)
+	if err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+
+	err = ioutil.WriteFile(m.localPath, raw, 0o600)
+	if err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+
+	return nil
+}
+
+// upload uploads the local state, and returns runErr, the error from running the upgrade, combined with any error
+// from uploading
+func (m Manager) upload(runErr error) error {
+	if m.readOnly {
+		return runErr
+	}
+
+	m.log.Info("Uploading state")
+
+	f, err := os.Open(filepath.Clean(m.localPath))
+	if err != nil {
+		return combine(runErr, fmt.Errorf("opening state: %w", err))
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	err = m.remote.Upload(f)
+	if err != nil {
+		return combine(runErr, fmt.Errorf("uploading state: %w", err))
+	}
+
+	return runErr
+}
+
+func (m Manager) releaseLock(runErr error) error {
+	m.log.Info("Releasing state lock")
+
+	err := m.remote.ReleaseLock()
+	if err != nil {
+		return combine(runErr, fmt.Errorf("releasing state lock: %w", err))
+	}
+
+	return runErr
+}
+
+// combine returns err, or cause with err appended if there already was an error
+func combine(cause error, err error) error {
+	if cause == nil {
+		return err
+	}
+
+	return fmt.Errorf("%w (%s)", cause, err.Error())
+}
+
+// New returns a manager that keeps the state at localPath in sync with the remote state
+func New(log logger.Logger, remote RemoteState, localPath string, opts Opts) Manager {
+	return Manager{
+		log:       log,
+		remote:    remote,
+		localPath: localPath,
+		readOnly:  opts.ReadOnly,
+	}
+}
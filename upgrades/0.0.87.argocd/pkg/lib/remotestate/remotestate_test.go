@@ -0,0 +1,179 @@
+package remotestate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/logger"
+)
+
+func TestManagerRun(t *testing.T) {
+	errUpgrade := errors.New("upgrade failed")
+
+	testCases := []struct {
+		name     string
+		readOnly bool
+		locked   bool
+		runErr   error
+		// interruptBefore sends a signal before the upgrade starts, and interruptDuring while it runs. interruptTwice
+		// sends another signal while the upgrade stops.
+		interruptBefore bool
+		interruptDuring bool
+		interruptTwice  bool
+
+		expectForceQuit bool
+
+		expectErr     error
+		expectRun     bool
+		expectUploads int
+		expectState   string
+	}{
+		{
+			name:          "Should upload the changed state after a successful upgrade",
+			expectRun:     true,
+			expectUploads: 1,
+			expectState:   "changed",
+		},
+		{
+			name:          "Should upload the changed state and release the lock when the upgrade fails",
+			runErr:        errUpgrade,
+			expectErr:     errUpgrade,
+			expectRun:     true,
+			expectUploads: 1,
+			expectState:   "changed",
+		},
+		{
+			name:          "Should not upload the state when read only",
+			readOnly:      true,
+			expectRun:     true,
+			expectUploads: 0,
+			expectState:   "original",
+		},
+		{
+			name:          "Should not run the upgrade when the state is locked by someone else",
+			locked:        true,
+			expectErr:     ErrLocked,
+			expectRun:     false,
+			expectUploads: 0,
+			expectState:   "original",
+		},
+		{
+			name:            "Should wait for the interrupted upgrade to stop before uploading and releasing the lock",
+			interruptDuring: true,
+			expectErr:       ErrInterrupted,
+			expectRun:       true,
+			expectUploads:   1,
+			expectState:     "changed",
+		},
+		{
+			name:            "Should let a third interrupt kill the process, but still wait for the upgrade after the second",
+			interruptDuring: true,
+			interruptTwice:  true,
+			expectForceQuit: true,
+			expectErr:       ErrInterrupted,
+			expectRun:       true,
+			expectUploads:   1,
+			expectState:     "changed",
+		},
+		{
+			name:            "Should release the lock without running the upgrade when interrupted while downloading",
+			interruptBefore: true,
+			expectErr:       ErrInterrupted,
+			expectRun:       false,
+			expectUploads:   0,
+			expectState:     "original",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			remote := &Memory{State: []byte("original"), Locked: tc.locked}
+			localPath := filepath.Join(t.TempDir(), "localState", "state.db")
+
+			interrupts := make(chan os.Signal, 1)
+			if tc.interruptBefore {
+				interrupts <- syscall.SIGINT
+			}
+
+			m := New(logger.New(logger.Error), remote, localPath, Opts{ReadOnly: tc.readOnly})
+			m.interrupts = interrupts
+
+			forceQuit := make(chan struct{})
+			m.stopInterrupts = func() {
+				close(forceQuit)
+			}
+
+			ran := false
+
+			err := m.Run(func(stop <-chan struct{}) error {
+				ran = true
+
+				downloaded, err := ioutil.ReadFile(localPath)
+				if err != nil {
+					t.Fatalf("reading downloaded state: %s", err)
+				}
+
+				if string(downloaded) != "original" {
+					t.Errorf("expected downloaded state 'original', got '%s'", downloaded)
+				}
+
+				if tc.interruptDuring {
+					interrupts <- syscall.SIGINT
+					<-stop
+				}
+
+				if tc.interruptTwice {
+					interrupts <- syscall.SIGINT
+
+					// Run must handle it before the upgrade returns
+					<-forceQuit
+				}
+
+				// Changed last, so that the change is only uploaded if Run waits for the upgrade to return
+				err = ioutil.WriteFile(localPath, []byte("changed"), 0o600)
+				if err != nil {
+					t.Fatalf("changing state: %s", err)
+				}
+
+				return tc.runErr
+			})
+
+			if !errors.Is(err, tc.expectErr) {
+				t.Errorf("expected error %v, got %v", tc.expectErr, err)
+			}
+
+			if ran != tc.expectRun {
+				t.Errorf("expected run to be %t, got %t", tc.expectRun, ran)
+			}
+
+			if remote.Uploads != tc.expectUploads {
+				t.Errorf("expected %d uploads, got %d", tc.expectUploads, remote.Uploads)
+			}
+
+			if string(remote.State) != tc.expectState {
+				t.Errorf("expected remote state '%s', got '%s'", tc.expectState, remote.State)
+			}
+
+			select {
+			case <-forceQuit:
+				if !tc.expectForceQuit {
+					t.Errorf("expected the default handling of interrupts not to be restored")
+				}
+			default:
+				if tc.expectForceQuit {
+					t.Errorf("expected the default handling of interrupts to be restored")
+				}
+			}
+
+			if remote.Locked != tc.locked {
+				t.Errorf("expected lock to be %t after running, got %t", tc.locked, remote.Locked)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package replay records the Kubernetes API traffic of an upgrade to a cassette file, and serves it back in tests.
+// An upgrade recorded once against a real cluster then becomes a deterministic offline test.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+)
+
+// Cassette is a recorded sequence of requests and responses
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request and the response to it
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Path includes the query.
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is a recorded response
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Load reads a cassette from a file
+func Load(path string) (Cassette, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Cassette{}, fmt.Errorf("reading cassette: %w", err)
+	}
+
+	var cassette Cassette
+
+	err = json.Unmarshal(raw, &cassette)
+	if err != nil {
+		return Cassette{}, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+
+	return cassette, nil
+}
+
+// Save writes the cassette to a file
+func (c Cassette) Save(path string) error {
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling cassette: %w", err)
+	}
+
+	err = ioutil.WriteFile(path, append(raw, '\n'), 0o600)
+	if err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+
+	return nil
+}
+
+// toBody stores a body as is if it is JSON, which Kubernetes bodies normally are, and as a JSON string otherwise
+func toBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	if json.Valid(body) {
+		return body
+	}
+
+	raw, _ := json.Marshal(string(body))
+
+	return raw
+}
+
+// fromBody returns the body as it was sent
+func fromBody(body json.RawMessage) []byte {
+	var s string
+
+	if json.Unmarshal(body, &s) == nil {
+		return []byte(s)
+	}
+
+	return body
+}
+
+// matches returns true if the request has the same method, path and body as the recorded one. JSON bodies are
+// compared by value, so that recorded bodies can be reformatted.
+func (r Request) matches(method, path string, body []byte) bool {
+	if r.Method != method || r.Path != path {
+		return false
+	}
+
+	recorded := fromBody(r.Body)
+
+	var expected, actual interface{}
+
+	if json.Unmarshal(recorded, &expected) == nil && json.Unmarshal(body, &actual) == nil {
+		return reflect.DeepEqual(expected, actual)
+	}
+
+	return string(recorded) == string(body)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
+	"github.com/spf13/cobra"
+)
+
+func buildPlanCommand(flags *cmdflags.Flags, manageState *bool, deps Dependencies) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Saves what the upgrade would do to a plan file, which can be reviewed and applied later",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			// The plan is written to stdout if no file is given, so it must be machine-readable
+			if out == "" && flags.Output == string(output.FormatText) {
+				flags.Output = string(output.FormatJSON)
+			}
+
+			context, err := newContext(*flags, deps)
+			if err != nil {
+				return err
+			}
+
+			// Planning never changes the state, so it doesn't need to be locked or uploaded
+			readOnly := *flags
+			readOnly.DryRun = true
+
+			return withManagedState(context, readOnly, *manageState, func(_ <-chan struct{}) error {
+				return plan(context, *flags, out)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Write the plan as JSON to this file instead of to stdout.")
+
+	return cmd
+}
+
+func buildApplyCommand(flags *cmdflags.Flags, manageState *bool, deps Dependencies) *cobra.Command {
+	return &cobra.Command{
+		Use:   "apply <plan file>",
+		Short: "Applies a plan file made by the plan command, if the cluster still matches it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Applying is the point of this command, so only simulate if explicitly asked to
+			flags.DryRun = flags.DryRun && cmd.Flags().Changed("dry-run")
+
+			context, err := newContext(*flags, deps)
+			if err != nil {
+				return err
+			}
+
+			return withManagedState(context, *flags, *manageState, func(stop <-chan struct{}) error {
+				return apply(context, *flags, args[0], stop)
+			})
+		},
+	}
+}
+
+func plan(context Context, flags cmdflags.Flags, out string) error {
+	recorder, err := trace.NewFile(flags.Trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	argocd, _, err := newArgoCD(context, argocdPkg.Opts{DryRun: true, Trace: recorder})
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := argocd.Checkpoints()
+	if err != nil {
+		return fmt.Errorf("getting checkpoints: %w", err)
+	}
+
+	e := engine.New(context.log, engine.Opts{
+		Access:      argocd.Access(),
+		Checkpoints: checkpoints,
+		Record:      newRecord(context, argocd),
+	})
+
+	p, err := e.Plan(argocd.Upgrade())
+	if err != nil {
+		return fmt.Errorf("planning upgrade: %w", err)
+	}
+
+	p.Blocked = argocd.Blocked()
+
+	if out == "" {
+		format, err := output.ParseFormat(flags.Output)
+		if err != nil {
+			return err
+		}
+
+		return output.Write(os.Stdout, format, p)
+	}
+
+	return writePlan(out, p)
+}
+
+func apply(context Context, flags cmdflags.Flags, planPath string, stop <-chan struct{}) error {
+	p, err := readPlan(planPath)
+	if err != nil {
+		return err
+	}
+
+	recorder, err := trace.NewFile(flags.Trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	argocd, _, err := newArgoCD(context, argocdPkg.Opts{DryRun: flags.DryRun, Trace: recorder})
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := argocd.Checkpoints()
+	if err != nil {
+		return fmt.Errorf("getting checkpoints: %w", err)
+	}
+
+	e := engine.New(context.log, engine.Opts{
+		DryRun:         flags.DryRun,
+		NoRollback:     flags.NoRollback,
+		Access:         argocd.Access(),
+		AcceptDataLoss: flags.AcceptDataLoss,
+		Stop:           stop,
+		Checkpoints:    checkpoints,
+		Record:         newRecord(context, argocd),
+		Events:         context.events,
+		ReportSkipped:  flags.ReportSkipped,
+	})
+
+	_, err = e.Apply(argocd.Upgrade(), p)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func writePlan(path string, p engine.Plan) error {
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling plan: %w", err)
+	}
+
+	err = ioutil.WriteFile(path, append(raw, '\n'), 0o600)
+	if err != nil {
+		return fmt.Errorf("writing plan file: %w", err)
+	}
+
+	return nil
+}
+
+func readPlan(path string) (engine.Plan, error) {
+	raw, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return engine.Plan{}, fmt.Errorf("reading plan file: %w", err)
+	}
+
+	var p engine.Plan
+
+	err = json.Unmarshal(raw, &p)
+	if err != nil {
+		return engine.Plan{}, fmt.Errorf("parsing plan file %s: %w", path, err)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/okctlstate"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/output"
+	"github.com/spf13/cobra"
+)
+
+// buildStateCommand works on the local okctl state, which isn't replaced in tests, so it doesn't take dependencies
+func buildStateCommand(flags *cmdflags.Flags, manageState *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "state",
+		Short: "Inspects and resets okctl's records of which upgrades have been applied",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists the upgrades recorded as applied in the local state",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			readOnly := *flags
+			readOnly.DryRun = true
+
+			context, err := newContext(*flags, Dependencies{})
+			if err != nil {
+				return err
+			}
+
+			return withManagedState(context, readOnly, *manageState, func(_ <-chan struct{}) error {
+				return listState(*flags)
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "reset <version>",
+		Short: "Removes the record of an upgrade, so that okctl upgrade runs it again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Resetting is the point of this command, so only simulate if explicitly asked to
+			flags.DryRun = flags.DryRun && cmd.Flags().Changed("dry-run")
+
+			context, err := newContext(*flags, Dependencies{})
+			if err != nil {
+				return err
+			}
+
+			return withManagedState(context, *flags, *manageState, func(_ <-chan struct{}) error {
+				return resetState(context, *flags, args[0])
+			})
+		},
+	})
+
+	return cmd
+}
+
+type stateRecord struct {
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func listState(flags cmdflags.Flags) error {
+	format, err := output.ParseFormat(flags.Output)
+	if err != nil {
+		return err
+	}
+
+	records, err := argocdPkg.Records()
+	if err != nil {
+		return err
+	}
+
+	upgrades, err := records.List()
+	if err != nil {
+		return err
+	}
+
+	list := make([]stateRecord, len(upgrades))
+	for i, upgrade := range upgrades {
+		list[i] = stateRecord{
+			Version:   upgrade.Version,
+			CreatedAt: upgrade.CreatedAt,
+			UpdatedAt: upgrade.UpdatedAt,
+		}
+	}
+
+	if format != output.FormatText {
+		return output.Write(os.Stdout, format, list)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	_, _ = fmt.Fprintln(w, "VERSION\tAPPLIED")
+	for _, r := range list {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", r.Version, r.UpdatedAt.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+func resetState(context Context, flags cmdflags.Flags, version string) error {
+	records, err := argocdPkg.Records()
+	if err != nil {
+		return err
+	}
+
+	if flags.DryRun {
+		_, found, err := records.Get(version)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return fmt.Errorf("upgrade %s: %w", version, okctlstate.ErrNotFound)
+		}
+
+		context.log.Infof("Simulating removal of the record of upgrade %s\n", version)
+
+		return nil
+	}
+
+	err = records.Delete(version)
+	if err != nil {
+		return err
+	}
+
+	context.log.Infof("Removed the record of upgrade %s\n", version)
+
+	return nil
+}
@@ -1,21 +1,92 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+
 	argocdPkg "github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/argocd"
 	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/cmdflags"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/engine"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/output"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/replay"
+	"github.com/oslokommune/okctl-upgrade/upgrades/0.0.87.argocd/pkg/lib/trace"
 )
 
-func upgrade(context Context, flags cmdflags.Flags) error {
-	argocd, err := argocdPkg.New(context.log, flags)
+// upgrade runs the upgrade. It stops after the current step and rolls back when stop is closed.
+func upgrade(context Context, flags cmdflags.Flags, stop <-chan struct{}) error {
+	format, err := output.ParseFormat(flags.Output)
+	if err != nil {
+		return err
+	}
+
+	if format != output.FormatText && !flags.DryRun {
+		return errors.New("--output=json|yaml can only be used together with --dry-run")
+	}
+
+	recorder, err := trace.NewFile(flags.Trace)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = recorder.Close()
+	}()
+
+	cassette := replay.NewRecorder(flags.Record)
+
+	argocd, clusterName, err := newArgoCD(context, argocdPkg.Opts{DryRun: flags.DryRun, Trace: recorder, Record: cassette})
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := argocd.Checkpoints()
 	if err != nil {
-		return fmt.Errorf("creating argocd: %w", err)
+		return fmt.Errorf("getting checkpoints: %w", err)
+	}
+
+	e := engine.New(context.log, engine.Opts{
+		DryRun:         flags.DryRun,
+		Confirm:        flags.Confirm,
+		Prompter:       context.prompter,
+		Access:         argocd.Access(),
+		ClusterName:    clusterName,
+		AcceptDataLoss: flags.AcceptDataLoss,
+		Stop:           stop,
+		NoRollback:     flags.NoRollback,
+		Checkpoints:    checkpoints,
+		Record:         newRecord(context, argocd),
+		Events:         context.events,
+		ReportSkipped:  flags.ReportSkipped,
+	})
+
+	if format != output.FormatText {
+		plan, err := e.Plan(argocd.Upgrade())
+		if err != nil {
+			return fmt.Errorf("planning upgrade: %w", err)
+		}
+
+		plan.Blocked = argocd.Blocked()
+
+		return output.Write(os.Stdout, format, plan)
+	}
+
+	_, err = e.Run(argocd.Upgrade())
+
+	for _, request := range argocd.Blocked() {
+		context.log.Infof("Blocked request that would have changed the cluster: %s\n", request)
 	}
 
-	err = argocd.Upgrade()
+	// Save the recording also if the upgrade failed, as failures are worth replaying too
+	saveErr := cassette.Save()
+
 	if err != nil {
 		return err
 	}
 
+	if saveErr != nil {
+		return fmt.Errorf("saving recording: %w", saveErr)
+	}
+
 	return nil
 }